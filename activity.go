@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Activity kinds. Everything but notes is recorded automatically.
+const (
+	activityCreated   = "created"
+	activityEdited    = "edited"
+	activityCompleted = "completed"
+	activityReopened  = "reopened"
+	activityNote      = "note"
+)
+
+type Activity struct {
+	At   time.Time
+	Kind string
+	Text string `json:",omitempty"`
+}
+
+func logActivity(todo *Todo, kind, text string) {
+	todo.Activity = append(todo.Activity, Activity{At: time.Now(), Kind: kind, Text: text})
+}
+
+func (m *model) addNote(id int, text string) {
+	i := m.todoIndex(id)
+	if i < 0 || text == "" {
+		return
+	}
+	logActivity(&m.todos[i], activityNote, text)
+	m.save()
+	m.updateTable()
+}
+
+func formatActivity(a Activity) string {
+	line := a.At.Format("Jan 2 15:04") + "  "
+	if a.Kind == activityNote {
+		return line + a.Text
+	}
+	line += "— " + a.Kind
+	if a.Text != "" {
+		line += ": " + a.Text
+	}
+	return line
+}
+
+func noteCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: todo note ID TEXT")
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		return fmt.Errorf("%q is not a todo number", args[0])
+	}
+	text := strings.TrimSpace(strings.Join(args[1:], " "))
+	if text == "" {
+		return fmt.Errorf("note is empty")
+	}
+
+	todos, err := loadTodos()
+	if err != nil {
+		return err
+	}
+	for i := range todos {
+		if todos[i].ID == id {
+			logActivity(&todos[i], activityNote, text)
+			return saveTodos(todos)
+		}
+	}
+	return fmt.Errorf("there is no todo #%d", id)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// selection returns the IDs of the marked todos in list order, or the todo
+// under the cursor when nothing is marked.
+func (m *model) selection() []int {
+	var ids []int
+	for _, todo := range m.todos {
+		if m.marked[todo.UID] {
+			ids = append(ids, todo.ID)
+		}
+	}
+	if len(ids) == 0 {
+		if todo := m.getCurrentTodo(); todo != nil {
+			ids = append(ids, todo.ID)
+		}
+	}
+	return ids
+}
+
+func (m *model) visibleTodoIDs() []int {
+	var ids []int
+	for _, todo := range m.todos {
+		if m.matchesFilter(todo) {
+			ids = append(ids, todo.ID)
+		}
+	}
+	return ids
+}
+
+func (m *model) toggleMark(id int) {
+	uid := m.uidOf(id)
+	if m.marked[uid] {
+		delete(m.marked, uid)
+	} else {
+		m.marked[uid] = true
+	}
+	m.markAnchor = uid
+	m.updateTable()
+}
+
+// markAll marks every todo in the current filter, or clears the marks if
+// they were all marked already.
+func (m *model) markAll() {
+	ids := m.visibleTodoIDs()
+	all := len(ids) > 0
+	for _, id := range ids {
+		if !m.marked[m.uidOf(id)] {
+			all = false
+			break
+		}
+	}
+
+	m.marked = map[string]bool{}
+	if !all {
+		for _, id := range ids {
+			m.marked[m.uidOf(id)] = true
+		}
+	}
+	m.updateTable()
+}
+
+// markRange marks every visible todo between the last toggled row and id.
+func (m *model) markRange(id int) {
+	ids := m.visibleTodoIDs()
+	from, to := -1, -1
+	for i, visibleID := range ids {
+		if m.uidOf(visibleID) == m.markAnchor {
+			from = i
+		}
+		if visibleID == id {
+			to = i
+		}
+	}
+	if to < 0 {
+		return
+	}
+	if from < 0 {
+		from = to
+	}
+	if from > to {
+		from, to = to, from
+	}
+
+	for _, visibleID := range ids[from : to+1] {
+		m.marked[m.uidOf(visibleID)] = true
+	}
+	m.markAnchor = m.uidOf(id)
+	m.updateTable()
+}
+
+func (m *model) clearMarks() {
+	m.marked = map[string]bool{}
+	m.updateTable()
+}
+
+// bulkUpdate applies fn to each of the given todos and saves once, so the
+// whole batch is a single undo step.
+func (m *model) bulkUpdate(ids []int, fn func(todo *Todo)) {
+	if len(ids) == 0 {
+		return
+	}
+	for _, id := range ids {
+		if i := m.todoIndex(id); i >= 0 {
+			fn(&m.todos[i])
+		}
+	}
+	m.save()
+	m.updateTable()
+}
+
+// bulkSetCompleted completes or reopens todos. Open sub-todos are only
+// completed along with them when complete_children is "cascade", since
+// prompting once per todo would defeat the point of a bulk action.
+func (m *model) bulkSetCompleted(ids []int, completed bool) {
+	if len(ids) == 0 {
+		return
+	}
+	for _, id := range ids {
+		if i := m.todoIndex(id); i >= 0 {
+			markTodoCompleted(&m.todos[i], completed)
+			if completed && cfg.CompleteChildren == cascadeAlways {
+				setSubTodosCompleted(m.todos[i].SubTodos, true)
+			}
+		}
+	}
+	m.spawnRecurrences()
+	m.save()
+	m.updateTable()
+}
+
+func (m *model) bulkDelete(ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	m.removeTodos(ids...)
+	m.marked = map[string]bool{}
+	m.save()
+	m.updateTable()
+}
+
+// bulkTag adds the space-separated tags in spec to each todo; a tag
+// written as "-name" is removed instead.
+func (m *model) bulkTag(ids []int, spec string) {
+	fields := strings.Fields(spec)
+	m.bulkUpdate(ids, func(todo *Todo) {
+		for _, field := range fields {
+			tag := strings.TrimLeft(field, "+-#")
+			if tag == "" {
+				continue
+			}
+			if strings.HasPrefix(field, "-") {
+				todo.Tags = removeTag(todo.Tags, tag)
+			} else if !hasTag(todo.Tags, tag) {
+				todo.Tags = append(todo.Tags, tag)
+			}
+		}
+	})
+}
+
+func (m *model) bulkSetPriority(ids []int, value string) error {
+	priority, err := parsePriority(value)
+	if err != nil {
+		return err
+	}
+	m.bulkUpdate(ids, func(todo *Todo) {
+		todo.Priority = priority
+	})
+	return nil
+}
+
+func (m *model) bulkSetDue(ids []int, value string) error {
+	due, err := parseDate(value, time.Now())
+	if err != nil {
+		return err
+	}
+	m.bulkUpdate(ids, func(todo *Todo) {
+		todo.Due = due
+	})
+	return nil
+}
+
+func (m *model) bulkSetArchived(ids []int, archived bool) {
+	m.bulkUpdate(ids, func(todo *Todo) {
+		todo.Archived = archived
+	})
+	m.marked = map[string]bool{}
+	m.updateTable()
+}
+
+func (m *model) allArchived(ids []int) bool {
+	for _, id := range ids {
+		if i := m.todoIndex(id); i >= 0 && !m.todos[i].Archived {
+			return false
+		}
+	}
+	return true
+}
+
+// purgeArchived permanently deletes every archived todo.
+func (m *model) purgeArchived() {
+	var ids []int
+	for _, todo := range m.todos {
+		if todo.Archived {
+			ids = append(ids, todo.ID)
+		}
+	}
+	m.bulkDelete(ids)
+}
+
+// parsePriority accepts a single letter A (highest) to Z, in either case,
+// optionally wrapped in parentheses. An empty value clears the priority.
+func parsePriority(value string) (string, error) {
+	value = strings.Trim(strings.TrimSpace(value), "()")
+	if value == "" {
+		return "", nil
+	}
+	r := []rune(strings.ToUpper(value))
+	if len(r) != 1 || r[0] < 'A' || r[0] > 'Z' {
+		return "", fmt.Errorf("priority must be a letter A-Z")
+	}
+	return string(r), nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeTag(tags []string, tag string) []string {
+	var kept []string
+	for _, t := range tags {
+		if !strings.EqualFold(t, tag) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// commands are the non-interactive subcommands, e.g. "todo time report".
+var commands = map[string]func(args []string) error{
+	"time":    timeCommand,
+	"note":    noteCommand,
+	"import":  importCommand,
+	"export":  exportCommand,
+	"doctor":  doctorCommand,
+	"migrate": migrateCommand,
+	"log":     logCommand,
+	"revert":  revertCommand,
+	"sync":    syncCommand,
+}
+
+func timeCommand(args []string) error {
+	usage := fmt.Errorf("usage: todo time report|estimates [--since DATE]")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "report":
+		return timeReport(args[1:])
+	case "estimates":
+		return estimateReport(args[1:])
+	}
+	return usage
+}
+
+func timeReport(args []string) error {
+	fs := flag.NewFlagSet("time report", flag.ContinueOnError)
+	since := fs.String("since", "", "only count time after this date (2006-01-02, today, mon, -7d, ...)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	from, err := parseSince(*since, now)
+	if err != nil {
+		return err
+	}
+
+	todos, err := loadTodos()
+	if err != nil {
+		return err
+	}
+
+	type line struct {
+		todo  Todo
+		spent time.Duration
+	}
+	var lines []line
+	var total time.Duration
+	for _, todo := range todos {
+		spent := trackedTime(todo, from, now)
+		if spent > 0 {
+			lines = append(lines, line{todo, spent})
+			total += spent
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].spent > lines[j].spent
+	})
+
+	if len(lines) == 0 {
+		fmt.Println("No time tracked.")
+		return nil
+	}
+	for _, l := range lines {
+		fmt.Printf("%9s  #%-4d %s\n", formatDuration(l.spent), l.todo.ID, l.todo.Title)
+	}
+	fmt.Printf("%9s  total\n", formatDuration(total))
+	return nil
+}
+
+// estimateReport compares estimated and tracked time for completed todos,
+// grouped by tag. A todo with several tags counts towards each of them.
+func estimateReport(args []string) error {
+	fs := flag.NewFlagSet("time estimates", flag.ContinueOnError)
+	since := fs.String("since", "", "only include todos completed after this date")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	from, err := parseSince(*since, now)
+	if err != nil {
+		return err
+	}
+
+	todos, err := loadTodos()
+	if err != nil {
+		return err
+	}
+
+	type group struct {
+		count     int
+		estimated time.Duration
+		actual    time.Duration
+	}
+	groups := map[string]*group{}
+	var names []string
+	for _, todo := range todos {
+		estimate := todoEstimate(todo)
+		if !todo.Completed || estimate == 0 || todo.CompletedAt.Before(from) {
+			continue
+		}
+
+		tags := todo.Tags
+		if len(tags) == 0 {
+			tags = []string{"(untagged)"}
+		}
+		for _, tag := range tags {
+			g, ok := groups[tag]
+			if !ok {
+				g = &group{}
+				groups[tag] = g
+				names = append(names, tag)
+			}
+			g.count++
+			g.estimated += estimate
+			g.actual += trackedTime(todo, time.Time{}, now)
+		}
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No completed todos with estimates.")
+		return nil
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%-20s %6s %10s %10s %7s\n", "TAG", "TODOS", "ESTIMATED", "ACTUAL", "RATIO")
+	for _, name := range names {
+		g := groups[name]
+		fmt.Printf("%-20s %6d %10s %10s %6.0f%%\n", name, g.count,
+			formatEstimate(g.estimated), formatEstimate(g.actual),
+			100*float64(g.actual)/float64(g.estimated))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Values for config.CompleteChildren.
+const (
+	cascadeOff    = "off"
+	cascadePrompt = "prompt"
+	cascadeAlways = "cascade"
+)
+
+type config struct {
+	// AutoCompleteParent completes a parent once all of its sub-todos are done.
+	AutoCompleteParent bool `json:"auto_complete_parent"`
+	// ReopenParent reopens a completed parent when one of its sub-todos is unchecked.
+	ReopenParent bool `json:"reopen_parent"`
+	// CompleteChildren decides what happens to open sub-todos when their
+	// parent is completed: "off", "prompt" or "cascade".
+	CompleteChildren string `json:"complete_children"`
+	// ConfirmDestructive asks before deleting, archiving or purging todos.
+	ConfirmDestructive bool `json:"confirm_destructive"`
+	// PomodoroWork and PomodoroBreak are the focus mode phase lengths in minutes.
+	PomodoroWork  int `json:"pomodoro_work"`
+	PomodoroBreak int `json:"pomodoro_break"`
+	// File is where todos are kept; a .json name selects the JSON format
+	// and a .journal name the journal.
+	// Empty means ~/Documents/todos.csv.
+	File string `json:"file,omitempty"`
+	// Git commits the todo file to the git repository it lives in after
+	// every save.
+	Git bool `json:"git,omitempty"`
+	// Sync is a folder shared between devices. When set, this device keeps
+	// its todos in <Sync>/<Device>.todo.json and merges the others' files in.
+	Sync string `json:"sync,omitempty"`
+	// Device names this device's file in Sync; empty means the host name.
+	Device string `json:"device,omitempty"`
+}
+
+func defaultConfig() config {
+	return config{
+		AutoCompleteParent: true,
+		ReopenParent:       true,
+		CompleteChildren:   cascadePrompt,
+		ConfirmDestructive: true,
+		PomodoroWork:       25,
+		PomodoroBreak:      5,
+	}
+}
+
+var cfg = defaultConfig()
+
+func getConfigFilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "todo.json"
+	}
+	return filepath.Join(dir, "todo", "config.json")
+}
+
+// loadConfig reads the config file over the defaults, so a missing file or
+// a file that only sets a few keys is fine.
+func loadConfig() (config, error) {
+	c := defaultConfig()
+
+	data, err := os.ReadFile(getConfigFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return c, err
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// saveConfig writes the config back, for commands that change it.
+func saveConfig(c config) error {
+	path := getConfigFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// confirmation is a yes/no question shown as a modal over the current
+// view. onNo may be nil, in which case "n" behaves like cancel.
+type confirmation struct {
+	message    string
+	onYes      func(m *model)
+	onNo       func(m *model)
+	returnMode viewMode
+}
+
+func (m *model) askConfirm(message string, onYes, onNo func(m *model)) {
+	m.confirm = confirmation{
+		message:    message,
+		onYes:      onYes,
+		onNo:       onNo,
+		returnMode: m.mode,
+	}
+	m.mode = confirmView
+}
+
+// confirmDestructive runs action straight away when confirmations are
+// turned off, and otherwise asks first.
+func (m *model) confirmDestructive(message string, action func(m *model)) {
+	if !cfg.ConfirmDestructive {
+		action(m)
+		return
+	}
+	m.askConfirm(message, action, nil)
+}
+
+// describeTodos names the todos an action is about to touch, listing a
+// few titles when there are several.
+func (m *model) describeTodos(verb string, ids []int) string {
+	var titles []string
+	for _, id := range ids {
+		if i := m.todoIndex(id); i >= 0 {
+			titles = append(titles, m.todos[i].Title)
+		}
+	}
+
+	if len(titles) == 1 {
+		return fmt.Sprintf("%s \"%s\"?", verb, titles[0])
+	}
+
+	msg := fmt.Sprintf("%s %d todos?\n", verb, len(titles))
+	for i, title := range titles {
+		if i == 5 {
+			msg += fmt.Sprintf("\n  ...and %d more", len(titles)-5)
+			break
+		}
+		msg += "\n  • " + title
+	}
+	return msg
+}
+
+func (m model) handleConfirmKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.confirm
+
+	switch msg.String() {
+	case "y", "Y":
+		m.mode = c.returnMode
+		m.confirm = confirmation{}
+		c.onYes(&m)
+	case "n", "N":
+		m.mode = c.returnMode
+		m.confirm = confirmation{}
+		if c.onNo != nil {
+			c.onNo(&m)
+		}
+	case "esc", "q", "ctrl+c":
+		m.mode = c.returnMode
+		m.confirm = confirmation{}
+	}
+	return m, nil
+}
+
+func (m model) renderConfirmView() string {
+	popupWidth := m.width - 20
+	if popupWidth > 60 {
+		popupWidth = 60
+	}
+	if popupWidth < 40 {
+		popupWidth = 40
+	}
+
+	help := "[y] yes  [n] no  [esc] cancel"
+	if m.confirm.onNo == nil {
+		help = "[y] yes  [n/esc] cancel"
+	}
+
+	content := titleStyle.Render("Confirm") + "\n\n"
+	content += m.confirm.message + "\n\n"
+	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(help)
+
+	popup := popupStyle.Width(popupWidth).Render(content)
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		popup,
+	)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"strings"
@@ -9,82 +11,335 @@ import (
 	"github.com/charmbracelet/bubbles/table"
 )
 
-func (m *model) addTodo(title, description string) {
+func (m *model) nextTodoID() int {
 	maxID := 0
 	for _, todo := range m.todos {
 		if todo.ID > maxID {
 			maxID = todo.ID
 		}
 	}
+	return maxID + 1
+}
+
+// newUID returns a random (version 4) UUID that, unlike the ID, never
+// changes and is unique across files, so exported todos can be matched up
+// again.
+func newUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+func formatUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// ensureUIDs gives todos from older files a UID and reports whether any
+// was missing.
+func ensureUIDs(todos []Todo) bool {
+	changed := false
+	for i := range todos {
+		if todos[i].UID == "" {
+			todos[i].UID = newUID()
+			changed = true
+		}
+	}
+	return changed
+}
 
+func (m *model) addTodo(title, description string) {
+	title, estimate := splitEstimate(title)
 	desc, subTodos := parseSubTodosFromDescription(description)
 
 	newTodo := Todo{
-		ID:          maxID + 1,
+		ID:          m.nextTodoID(),
+		UID:         newUID(),
 		Title:       title,
+		Estimate:    estimate,
 		Description: desc,
 		Completed:   false,
 		CreatedAt:   time.Now(),
 		SubTodos:    subTodos,
+		Position:    len(m.todos) + 1,
 	}
+	logActivity(&newTodo, activityCreated, "")
+	recordRevision(&newTodo)
 
 	m.todos = append(m.todos, newTodo)
-	saveTodos(m.todos)
+	m.save()
 	m.updateTable()
 }
 
 func (m *model) updateTodo(id int, title, description string) {
+	title, estimate := splitEstimate(title)
 	desc, subTodos := parseSubTodosFromDescription(description)
 
 	for i, todo := range m.todos {
 		if todo.ID == id {
+			if len(todo.Revisions) == 0 {
+				// Todos from before revisions existed get their
+				// pre-edit content as a first revision.
+				recordRevision(&m.todos[i])
+				if !todo.CreatedAt.IsZero() {
+					m.todos[i].Revisions[0].At = todo.CreatedAt
+				}
+			}
 			m.todos[i].Title = title
+			m.todos[i].Estimate = estimate
 			m.todos[i].Description = desc
 			m.todos[i].SubTodos = subTodos
+			logActivity(&m.todos[i], activityEdited, "")
+			recordRevision(&m.todos[i])
 			break
 		}
 	}
-	saveTodos(m.todos)
+	m.save()
 	m.updateTable()
 }
 
 func (m *model) deleteTodo(id int) {
-	for i, todo := range m.todos {
-		if todo.ID == id {
+	m.removeTodos(id)
+	m.save()
+	m.updateTable()
+}
+
+// removeTodos drops todos from the list and renumbers the rest without
+// saving.
+func (m *model) removeTodos(ids ...int) {
+	for _, id := range ids {
+		if i := m.todoIndex(id); i >= 0 {
+			delete(m.marked, m.todos[i].UID)
+			delete(m.expanded, m.todos[i].UID)
 			m.todos = append(m.todos[:i], m.todos[i+1:]...)
-			break
 		}
 	}
 
+	newIDs := map[int]int{}
 	for i := range m.todos {
+		newIDs[m.todos[i].ID] = i + 1
 		m.todos[i].ID = i + 1
 	}
+	remapBlockers(m.todos, newIDs)
+	normalizePositions(m.todos)
+}
+
+// promoteSubTodo turns a sub-todo into a top-level todo, carrying its
+// completion state and children along.
+func (m *model) promoteSubTodo(id int, path []int) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	sub := subTodoAt(&m.todos[i].SubTodos, path)
+	if sub == nil {
+		return
+	}
+
+	newTodo := Todo{
+		ID:        m.nextTodoID(),
+		UID:       newUID(),
+		Title:     sub.Title,
+		Completed: sub.Completed,
+		Estimate:  sub.Estimate,
+		CreatedAt: time.Now(),
+		SubTodos:  sub.Children,
+		Position:  len(m.todos) + 1,
+	}
+	if newTodo.Completed {
+		newTodo.CompletedAt = newTodo.CreatedAt
+	}
+	logActivity(&newTodo, activityCreated, fmt.Sprintf("promoted from a sub-todo of #%d", id))
+	recordRevision(&newTodo)
+	renumberSubTodos(newTodo.SubTodos)
 
-	saveTodos(m.todos)
+	list := subTodoList(&m.todos[i].SubTodos, path)
+	idx := path[len(path)-1]
+	*list = append((*list)[:idx], (*list)[idx+1:]...)
+	renumberSubTodos(m.todos[i].SubTodos)
+
+	visible := len(visibleSubTodos(m.todos[i].SubTodos))
+	if m.selectedSubIdx >= visible && m.selectedSubIdx > 0 {
+		m.selectedSubIdx = visible - 1
+	}
+
+	m.todos = append(m.todos, newTodo)
+	m.save()
 	m.updateTable()
 }
 
-func (m *model) toggleComplete(id int) {
-	for i, todo := range m.todos {
-		if todo.ID == id {
-			m.todos[i].Completed = !m.todos[i].Completed
-			if m.todos[i].Completed {
-				m.todos[i].CompletedAt = time.Now()
-			} else {
-				m.todos[i].CompletedAt = time.Time{}
-			}
-			break
+// demoteTodo moves a todo under another one as its last sub-todo. The
+// todo's own sub-todos come along as children, and since sub-todos have
+// no description of their own, any description is appended to the
+// parent's. Anything else a sub-todo can't hold is lost, so it asks first
+// when the todo has any.
+func (m *model) demoteTodo(id, parentID int) {
+	i := m.todoIndex(id)
+	if i < 0 || id == parentID || m.todoIndex(parentID) < 0 {
+		return
+	}
+	if lost := demoteLosses(m.todos[i]); len(lost) > 0 {
+		m.askConfirm(
+			fmt.Sprintf("A sub-todo can't keep the %s of \"%s\". Demote it anyway?", joinWords(lost), m.todos[i].Title),
+			func(m *model) { m.demoteTodoConfirmed(id, parentID) },
+			nil,
+		)
+		return
+	}
+	m.demoteTodoConfirmed(id, parentID)
+}
+
+func (m *model) demoteTodoConfirmed(id, parentID int) {
+	i := m.todoIndex(id)
+	if i < 0 || id == parentID || m.todoIndex(parentID) < 0 {
+		return
+	}
+	todo := m.todos[i]
+
+	p := m.todoIndex(parentID)
+	m.todos[p].SubTodos = append(m.todos[p].SubTodos, SubTodo{
+		Title:     todo.Title,
+		Completed: todo.Completed,
+		Estimate:  todo.Estimate,
+		Children:  todo.SubTodos,
+	})
+	renumberSubTodos(m.todos[p].SubTodos)
+
+	if todo.Description != "" {
+		if m.todos[p].Description != "" {
+			m.todos[p].Description += "\n"
 		}
+		m.todos[p].Description += todo.Title + ": " + todo.Description
 	}
-	saveTodos(m.todos)
+
+	m.removeTodos(id)
+	m.save()
 	m.updateTable()
 }
 
+// demoteLosses names what a todo has that demoting it would drop. The
+// activity and revision every todo starts with don't count.
+func demoteLosses(todo Todo) []string {
+	var lost []string
+	if len(todo.Tags) > 0 {
+		lost = append(lost, "tags")
+	}
+	if len(todo.Projects) > 0 || len(todo.Contexts) > 0 {
+		lost = append(lost, "projects and contexts")
+	}
+	if todo.Priority != "" {
+		lost = append(lost, "priority")
+	}
+	if !todo.Due.IsZero() || !todo.DeferUntil.IsZero() {
+		lost = append(lost, "dates")
+	}
+	if todo.Recurrence != "" {
+		lost = append(lost, "repeat rule")
+	}
+	if len(todo.BlockedBy) > 0 {
+		lost = append(lost, "blockers")
+	}
+	if len(todo.TimeEntries) > 0 || len(todo.Pomodoros) > 0 {
+		lost = append(lost, "tracked time")
+	}
+	if len(todo.Activity) > 1 || len(todo.Revisions) > 1 {
+		lost = append(lost, "notes and history")
+	}
+	return lost
+}
+
+// joinWords lists words as "a, b and c".
+func joinWords(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
+
+func (m *model) toggleComplete(id int) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	todo := m.todos[i]
+
+	if blockers := m.openBlockers(todo); !todo.Completed && len(blockers) > 0 {
+		m.askConfirm(
+			fmt.Sprintf("\"%s\" is blocked by %s. Complete it anyway?", todo.Title, formatTodoList(blockers)),
+			func(m *model) { m.toggleCompleteUnblocked(id) },
+			nil,
+		)
+		return
+	}
+	m.toggleCompleteUnblocked(id)
+}
+
+func (m *model) toggleCompleteUnblocked(id int) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	todo := m.todos[i]
+
+	if todo.Completed || !hasOpenSubTodos(todo.SubTodos) {
+		m.setTodoCompleted(id, !todo.Completed, false)
+		return
+	}
+
+	switch cfg.CompleteChildren {
+	case cascadeAlways:
+		m.setTodoCompleted(id, true, true)
+	case cascadePrompt:
+		m.askConfirm(
+			fmt.Sprintf("\"%s\" has open sub-todos. Complete them too?", todo.Title),
+			func(m *model) { m.setTodoCompleted(id, true, true) },
+			func(m *model) { m.setTodoCompleted(id, true, false) },
+		)
+	default:
+		m.setTodoCompleted(id, true, false)
+	}
+}
+
+// setTodoCompleted marks a todo done or open, optionally applying the same
+// state to all of its sub-todos.
+func (m *model) setTodoCompleted(id int, completed, cascade bool) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+
+	markTodoCompleted(&m.todos[i], completed)
+	if cascade {
+		setSubTodosCompleted(m.todos[i].SubTodos, completed)
+	}
+	m.spawnRecurrences()
+
+	m.save()
+	m.updateTable()
+}
+
+func markTodoCompleted(todo *Todo, completed bool) {
+	if todo.Completed == completed {
+		return
+	}
+	todo.Completed = completed
+	if completed {
+		todo.CompletedAt = time.Now()
+		logActivity(todo, activityCompleted, "")
+	} else {
+		todo.CompletedAt = time.Time{}
+		logActivity(todo, activityReopened, "")
+	}
+}
+
 func (m *model) getCurrentTodo() *Todo {
-	if len(m.table.SelectedRow()) == 0 {
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.rows) {
 		return nil
 	}
-	id, _ := strconv.Atoi(m.table.SelectedRow()[0])
+	id := m.rows[cursor].id
 	for _, todo := range m.todos {
 		if todo.ID == id {
 			return &todo
@@ -93,13 +348,22 @@ func (m *model) getCurrentTodo() *Todo {
 	return nil
 }
 
+// currentSubPath is the path of the sub-todo under the cursor, or nil when
+// the cursor is on a todo.
+func (m *model) currentSubPath() []int {
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.rows) {
+		return nil
+	}
+	return m.rows[cursor].path
+}
+
 func (m *model) updateTable() {
 	rows := []table.Row{}
+	var tableRows []tableRow
+	timing, _ := runningTimer(m.todos)
 	for _, todo := range m.todos {
-		if m.filter == showActive && todo.Completed {
-			continue
-		}
-		if m.filter == showCompleted && !todo.Completed {
+		if !m.matchesFilter(todo) {
 			continue
 		}
 
@@ -108,27 +372,209 @@ func (m *model) updateTable() {
 			completed = "[✓]"
 		}
 
-		desc := todo.Description
+		title := todo.Title
+		if todo.Priority != "" {
+			title = "(" + todo.Priority + ") " + title
+		}
+		for _, tag := range todo.Tags {
+			title += " #" + tag
+		}
+		for _, project := range todo.Projects {
+			title += " +" + project
+		}
+		for _, context := range todo.Contexts {
+			title += " @" + context
+		}
+		if todo.Recurrence != "" {
+			title = "↻ " + title
+		}
+		if isDeferred(todo, time.Now()) {
+			title = "zZ " + title
+		}
+		if todo.Estimate > 0 {
+			title += " ~" + formatEstimate(todo.Estimate)
+		}
+		if timing >= 0 && m.todos[timing].ID == todo.ID {
+			title = "⏱ " + title
+		}
+
+		blockers := m.openBlockers(todo)
+		if len(blockers) > 0 {
+			title = dimCell(title, m.columnWidth(1))
+			completed = "[⊘]"
+		}
 		if len(todo.SubTodos) > 0 {
-			completedCount := 0
-			for _, sub := range todo.SubTodos {
-				if sub.Completed {
-					completedCount++
-				}
+			if m.expanded[todo.UID] {
+				title = "▾ " + title
+			} else {
+				title = "▸ " + title
 			}
-			desc += fmt.Sprintf(" (%d/%d done)", completedCount, len(todo.SubTodos))
+		}
+
+		id := strconv.Itoa(todo.ID)
+		if m.marked[todo.UID] {
+			id = "•" + id
+		}
+
+		desc := todo.Description
+		if len(todo.SubTodos) > 0 {
+			done, total := subTodoProgress(todo.SubTodos)
+			desc += fmt.Sprintf(" (%d/%d done)", done, total)
+		}
+		if len(blockers) > 0 {
+			desc = dimCell(fmt.Sprintf("blocked by %s · %s", formatTodoList(blockers), desc), m.columnWidth(2))
 		}
 
 		rows = append(rows, table.Row{
-			strconv.Itoa(todo.ID),
-			todo.Title,
+			id,
+			title,
 			desc,
+			formatDate(todo.Due),
 			completed,
 		})
+		tableRows = append(tableRows, tableRow{id: todo.ID})
+
+		if !m.expanded[todo.UID] {
+			continue
+		}
+		for _, ref := range visibleSubTodos(todo.SubTodos) {
+			sub := subTodoAt(&todo.SubTodos, ref.path)
+			rows = append(rows, table.Row{
+				"",
+				subTodoLabel(*sub, ref.depth+1),
+				subTodoSummary(*sub),
+				"",
+				subTodoCheckbox(*sub),
+			})
+			tableRows = append(tableRows, tableRow{id: todo.ID, path: ref.path})
+		}
 	}
+	m.rows = tableRows
 	m.table.SetRows(rows)
 }
 
+func (m *model) columnWidth(i int) int {
+	columns := m.table.Columns()
+	if i >= len(columns) {
+		return 0
+	}
+	return columns[i].Width
+}
+
+func subTodoCheckbox(sub SubTodo) string {
+	if sub.Completed {
+		return "[✓]"
+	}
+	return "[ ]"
+}
+
+// subTodoLabel renders a sub-todo title indented to its depth, with a
+// fold marker when it has children.
+func subTodoLabel(sub SubTodo, depth int) string {
+	marker := "  "
+	if len(sub.Children) > 0 {
+		marker = "▾ "
+		if sub.Collapsed {
+			marker = "▸ "
+		}
+	}
+	return strings.Repeat("  ", depth) + marker + withEstimate(sub.Title, sub.Estimate)
+}
+
+func subTodoSummary(sub SubTodo) string {
+	if len(sub.Children) == 0 {
+		return ""
+	}
+	done, total := subTodoProgress(sub.Children)
+	return fmt.Sprintf("(%d/%d done)", done, total)
+}
+
+func (m *model) toggleExpanded(id int) {
+	uid := m.uidOf(id)
+	if m.expanded[uid] {
+		delete(m.expanded, uid)
+	} else {
+		m.expanded[uid] = true
+	}
+	m.updateTable()
+	m.selectTodoRow(id)
+}
+
+func (m *model) selectTodoRow(id int) {
+	for i, row := range m.rows {
+		if row.id == id && row.path == nil {
+			m.table.SetCursor(i)
+			return
+		}
+	}
+}
+
+func (m *model) matchesFilter(todo Todo) bool {
+	if m.filter == showArchived {
+		return todo.Archived
+	}
+	if todo.Archived {
+		return false
+	}
+
+	switch m.filter {
+	case showActive:
+		return !todo.Completed && !isDeferred(todo, time.Now())
+	case showCompleted:
+		return todo.Completed
+	case showDeferred:
+		return isDeferred(todo, time.Now())
+	}
+	return true
+}
+
+// moveTodo shifts a todo past its neighbours in the current filter by
+// delta, or to the top or bottom of the list when toEnd is set. Todos
+// hidden by the filter keep their place relative to each other.
+func (m *model) moveTodo(id, delta int, toEnd bool) {
+	var visible []int
+	k := -1
+	for _, todo := range m.todos {
+		if m.matchesFilter(todo) {
+			if todo.ID == id {
+				k = len(visible)
+			}
+			visible = append(visible, todo.ID)
+		}
+	}
+	if k < 0 {
+		return
+	}
+
+	target := k + delta
+	if toEnd {
+		target = 0
+		if delta > 0 {
+			target = len(visible) - 1
+		}
+	}
+	if target < 0 || target >= len(visible) || target == k {
+		return
+	}
+
+	i := m.todoIndex(id)
+	todo := m.todos[i]
+	m.todos = append(m.todos[:i], m.todos[i+1:]...)
+
+	pos := m.todoIndex(visible[target])
+	if target > k {
+		pos++
+	}
+	m.todos = append(m.todos, Todo{})
+	copy(m.todos[pos+1:], m.todos[pos:])
+	m.todos[pos] = todo
+	normalizePositions(m.todos)
+
+	m.save()
+	m.updateTable()
+	m.selectTodoRow(id)
+}
+
 func (m *model) toggleSubTodo(idx int) {
 	if idx < 0 {
 		return
@@ -142,58 +588,350 @@ func (m *model) toggleSubTodo(idx int) {
 		if currentTodo == nil {
 			return
 		}
-		for i, todo := range m.todos {
-			if todo.ID == currentTodo.ID {
-				todoIdx = i
-				found = true
-				break
-			}
-		}
+		todoIdx = m.todoIndex(currentTodo.ID)
+		found = todoIdx >= 0
 	} else if m.mode == editView && m.editingID != 0 {
-		for i, todo := range m.todos {
-			if todo.ID == m.editingID {
-				todoIdx = i
-				found = true
-				break
-			}
-		}
+		todoIdx = m.todoIndex(m.editingID)
+		found = todoIdx >= 0
 	}
 
-	if !found || idx >= len(m.todos[todoIdx].SubTodos) {
+	if !found {
+		return
+	}
+	refs := visibleSubTodos(m.todos[todoIdx].SubTodos)
+	if idx >= len(refs) {
 		return
 	}
 
-	m.todos[todoIdx].SubTodos[idx].Completed = !m.todos[todoIdx].SubTodos[idx].Completed
+	m.toggleSubTodoAt(m.todos[todoIdx].ID, refs[idx].path)
+}
 
-	if m.mode == detailView {
-		saveTodos(m.todos)
+// toggleSubTodoAt flips a sub-todo, asking or cascading per config when it
+// is being checked while it still has open children.
+func (m *model) toggleSubTodoAt(id int, path []int) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	sub := subTodoAt(&m.todos[i].SubTodos, path)
+	if sub == nil {
+		return
+	}
+
+	if sub.Completed || !hasOpenSubTodos(sub.Children) {
+		m.setSubTodoCompleted(id, path, !sub.Completed, false)
+		return
+	}
+
+	switch cfg.CompleteChildren {
+	case cascadeAlways:
+		m.setSubTodoCompleted(id, path, true, true)
+	case cascadePrompt:
+		m.askConfirm(
+			fmt.Sprintf("\"%s\" has open sub-todos. Complete them too?", sub.Title),
+			func(m *model) { m.setSubTodoCompleted(id, path, true, true) },
+			func(m *model) { m.setSubTodoCompleted(id, path, true, false) },
+		)
+	default:
+		m.setSubTodoCompleted(id, path, true, false)
+	}
+}
+
+// setSubTodoCompleted checks or unchecks a sub-todo, then applies the
+// configured rules to its ancestors: completing parents whose children
+// are now all done, or reopening parents of an unchecked item.
+func (m *model) setSubTodoCompleted(id int, path []int, completed, cascade bool) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	sub := subTodoAt(&m.todos[i].SubTodos, path)
+	if sub == nil {
+		return
+	}
+
+	sub.Completed = completed
+	if cascade {
+		setSubTodosCompleted(sub.Children, completed)
+	}
+
+	for depth := len(path) - 1; depth > 0; depth-- {
+		parent := subTodoAt(&m.todos[i].SubTodos, path[:depth])
+		if completed && cfg.AutoCompleteParent && !hasOpenSubTodos(parent.Children) {
+			parent.Completed = true
+		} else if !completed && cfg.ReopenParent {
+			parent.Completed = false
+		} else {
+			break
+		}
+	}
+
+	if completed && cfg.AutoCompleteParent && !hasOpenSubTodos(m.todos[i].SubTodos) {
+		markTodoCompleted(&m.todos[i], true)
+	} else if !completed && cfg.ReopenParent {
+		markTodoCompleted(&m.todos[i], false)
+	}
+
+	m.spawnRecurrences()
+
+	if m.mode != editView {
+		m.recordEditRevision(i)
+		m.save()
 		m.updateTable()
 	}
 }
 
+func (m *model) todoIndex(id int) int {
+	for i, todo := range m.todos {
+		if todo.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// uidOf returns the UID of todo #id. Marks and expanded rows are kept by
+// UID, since deleting a todo renumbers the ones after it.
+func (m *model) uidOf(id int) string {
+	if i := m.todoIndex(id); i >= 0 {
+		return m.todos[i].UID
+	}
+	return ""
+}
+
+// selectedSubPath returns the tree path of the sub-todo under the detail
+// view cursor, or nil when nothing is selected.
+func (m *model) selectedSubPath(todo *Todo) []int {
+	refs := visibleSubTodos(todo.SubTodos)
+	if m.selectedSubIdx < 0 || m.selectedSubIdx >= len(refs) {
+		return nil
+	}
+	return refs[m.selectedSubIdx].path
+}
+
+func (m *model) selectSubPath(id int, path []int) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	for idx, ref := range visibleSubTodos(m.todos[i].SubTodos) {
+		if samePath(ref.path, path) {
+			m.selectedSubIdx = idx
+			return
+		}
+	}
+}
+
+func (m *model) addSubTodo(id int, after []int, title string) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+
+	path := []int{len(m.todos[i].SubTodos)}
+	if after != nil {
+		path = append(append([]int{}, after[:len(after)-1]...), after[len(after)-1]+1)
+	}
+
+	list := subTodoList(&m.todos[i].SubTodos, path)
+	if list == nil {
+		return
+	}
+	title, estimate := splitEstimate(title)
+	*list = insertSubTodo(*list, path[len(path)-1], SubTodo{Title: title, Estimate: estimate})
+	renumberSubTodos(m.todos[i].SubTodos)
+
+	m.selectSubPath(id, path)
+	m.recordEditRevision(i)
+	m.save()
+	m.updateTable()
+}
+
+func (m *model) renameSubTodo(id int, path []int, title string) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	sub := subTodoAt(&m.todos[i].SubTodos, path)
+	if sub == nil {
+		return
+	}
+
+	sub.Title, sub.Estimate = splitEstimate(title)
+	m.recordEditRevision(i)
+	m.save()
+	m.updateTable()
+}
+
+func (m *model) deleteSubTodo(id int, path []int) {
+	i := m.todoIndex(id)
+	if i < 0 || subTodoAt(&m.todos[i].SubTodos, path) == nil {
+		return
+	}
+
+	list := subTodoList(&m.todos[i].SubTodos, path)
+	idx := path[len(path)-1]
+	*list = append((*list)[:idx], (*list)[idx+1:]...)
+	renumberSubTodos(m.todos[i].SubTodos)
+
+	visible := len(visibleSubTodos(m.todos[i].SubTodos))
+	if m.selectedSubIdx >= visible && m.selectedSubIdx > 0 {
+		m.selectedSubIdx = visible - 1
+	}
+	m.recordEditRevision(i)
+	m.save()
+	m.updateTable()
+}
+
+func (m *model) moveSubTodo(id int, path []int, delta int) {
+	i := m.todoIndex(id)
+	if i < 0 || subTodoAt(&m.todos[i].SubTodos, path) == nil {
+		return
+	}
+
+	list := *subTodoList(&m.todos[i].SubTodos, path)
+	idx := path[len(path)-1]
+	target := idx + delta
+	if target < 0 || target >= len(list) {
+		return
+	}
+
+	list[idx], list[target] = list[target], list[idx]
+	renumberSubTodos(m.todos[i].SubTodos)
+
+	newPath := append(append([]int{}, path[:len(path)-1]...), target)
+	m.selectSubPath(id, newPath)
+	m.recordEditRevision(i)
+	m.save()
+	m.updateTable()
+}
+
+// indentSubTodo makes a sub-todo the last child of its previous sibling.
+func (m *model) indentSubTodo(id int, path []int) {
+	i := m.todoIndex(id)
+	if i < 0 || subTodoAt(&m.todos[i].SubTodos, path) == nil {
+		return
+	}
+
+	idx := path[len(path)-1]
+	if idx == 0 {
+		return
+	}
+
+	list := subTodoList(&m.todos[i].SubTodos, path)
+	sub := (*list)[idx]
+	*list = append((*list)[:idx], (*list)[idx+1:]...)
+
+	parent := &(*list)[idx-1]
+	parent.Collapsed = false
+	parent.Children = append(parent.Children, sub)
+	renumberSubTodos(m.todos[i].SubTodos)
+
+	newPath := append(append([]int{}, path[:len(path)-1]...), idx-1, len(parent.Children)-1)
+	m.selectSubPath(id, newPath)
+	m.recordEditRevision(i)
+	m.save()
+	m.updateTable()
+}
+
+// outdentSubTodo moves a sub-todo out of its parent, placing it right
+// after the parent.
+func (m *model) outdentSubTodo(id int, path []int) {
+	i := m.todoIndex(id)
+	if i < 0 || len(path) < 2 || subTodoAt(&m.todos[i].SubTodos, path) == nil {
+		return
+	}
+
+	list := subTodoList(&m.todos[i].SubTodos, path)
+	idx := path[len(path)-1]
+	sub := (*list)[idx]
+	*list = append((*list)[:idx], (*list)[idx+1:]...)
+
+	parentPath := path[:len(path)-1]
+	grandList := subTodoList(&m.todos[i].SubTodos, parentPath)
+	pos := parentPath[len(parentPath)-1] + 1
+	*grandList = insertSubTodo(*grandList, pos, sub)
+	renumberSubTodos(m.todos[i].SubTodos)
+
+	newPath := append(append([]int{}, parentPath[:len(parentPath)-1]...), pos)
+	m.selectSubPath(id, newPath)
+	m.recordEditRevision(i)
+	m.save()
+	m.updateTable()
+}
+
+func (m *model) toggleSubTodoCollapsed(id int, path []int) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	sub := subTodoAt(&m.todos[i].SubTodos, path)
+	if sub == nil || len(sub.Children) == 0 {
+		return
+	}
+
+	sub.Collapsed = !sub.Collapsed
+	m.selectSubPath(id, path)
+	m.save()
+	m.updateTable()
+}
+
+// parseSubTodosFromDescription splits "- " lines out of a description into
+// sub-todos. Indenting a "- " line deeper than the one above nests it under
+// that item.
 func parseSubTodosFromDescription(description string) (string, []SubTodo) {
+	return splitListItems(description, false, func(trimmed string) (string, bool, bool) {
+		text, ok := strings.CutPrefix(trimmed, "- ")
+		return strings.TrimSpace(text), false, ok
+	})
+}
+
+// splitListItems does the work for parseSubTodosFromDescription and
+// parseChecklist. item reports whether a trimmed line is a list item and
+// returns its text and whether it is checked. With paragraphs set, blank
+// lines between paragraphs of the remaining text are kept.
+func splitListItems(description string, paragraphs bool, item func(trimmed string) (string, bool, bool)) (string, []SubTodo) {
 	lines := strings.Split(description, "\n")
 	var descLines []string
 	var subTodos []SubTodo
-	subID := 1
+
+	type level struct {
+		indent int
+		path   []int
+	}
+	var stack []level
 
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 
-		if strings.HasPrefix(trimmed, "- ") {
-			subText := strings.TrimPrefix(trimmed, "- ")
-			subText = strings.TrimSpace(subText)
-
+		if subText, completed, ok := item(trimmed); ok {
+			subText, estimate := splitEstimate(subText)
 			if subText != "" {
-				subTodos = append(subTodos, SubTodo{
-					ID:        subID,
+				indent := lineIndent(line)
+				for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+					stack = stack[:len(stack)-1]
+				}
+
+				list := &subTodos
+				var path []int
+				if len(stack) > 0 {
+					path = stack[len(stack)-1].path
+					list = &subTodoAt(&subTodos, path).Children
+				}
+				*list = append(*list, SubTodo{
+					ID:        len(*list) + 1,
 					Title:     subText,
-					Completed: false,
+					Completed: completed,
+					Estimate:  estimate,
 				})
-				subID++
+
+				childPath := append(append([]int{}, path...), len(*list)-1)
+				stack = append(stack, level{indent: indent, path: childPath})
 			}
 		} else if trimmed != "" {
 			descLines = append(descLines, line)
+		} else if paragraphs && len(descLines) > 0 && descLines[len(descLines)-1] != "" {
+			descLines = append(descLines, "")
 		}
 	}
 
@@ -203,3 +941,28 @@ func parseSubTodosFromDescription(description string) (string, []SubTodo) {
 	return cleanDesc, subTodos
 }
 
+// formatSubTodos is the inverse of parseSubTodosFromDescription, writing
+// the tree back out as indented "- " lines.
+func formatSubTodos(subTodos []SubTodo, depth int) string {
+	var b strings.Builder
+	for _, sub := range subTodos {
+		b.WriteString(strings.Repeat("  ", depth) + "- " + withEstimate(sub.Title, sub.Estimate) + "\n")
+		b.WriteString(formatSubTodos(sub.Children, depth+1))
+	}
+	return b.String()
+}
+
+func lineIndent(line string) int {
+	indent := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 4
+		default:
+			return indent
+		}
+	}
+	return indent
+}
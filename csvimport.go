@@ -0,0 +1,547 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Importing CSV files written by spreadsheets and other apps. Columns are
+// mapped to todo fields by --map, by a mapping screen, or by guessing from
+// the header; rows that don't parse are listed instead of being dropped.
+
+type csvOptions struct {
+	mapping    string
+	comma      string
+	dateLayout string
+	yes        bool
+}
+
+func (o *csvOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.mapping, "map", "", "csv: FIELD=COLUMN pairs, e.g. title=Task,due=Deadline (columns by name or number)")
+	fs.StringVar(&o.comma, "comma", ",", "csv: field separator")
+	fs.StringVar(&o.dateLayout, "date-format", "", "csv: Go layout for dates, e.g. 02/01/2006 (ISO dates are always understood)")
+	fs.BoolVar(&o.yes, "yes", false, "csv: import without the mapping screen or preview")
+}
+
+// csvField is a todo field a column can be mapped to.
+type csvField struct {
+	name     string
+	label    string
+	synonyms []string
+	set      func(todo *Todo, value string, o csvOptions) error
+}
+
+var csvFields = []csvField{
+	{"title", "Title", []string{"title", "name", "task", "summary", "subject", "todo"}, func(t *Todo, v string, _ csvOptions) error {
+		t.Title, t.Estimate = splitEstimate(v)
+		return nil
+	}},
+	{"description", "Description", []string{"description", "notes", "note", "details", "body"}, func(t *Todo, v string, _ csvOptions) error {
+		t.Description, t.SubTodos = parseChecklist(v)
+		return nil
+	}},
+	{"completed", "Done", []string{"completed", "done", "status", "state", "complete"}, func(t *Todo, v string, _ csvOptions) error {
+		done, err := parseDone(v)
+		t.Completed = done
+		return err
+	}},
+	{"priority", "Priority", []string{"priority", "prio", "importance"}, func(t *Todo, v string, _ csvOptions) error {
+		p, err := parseCSVPriority(v)
+		t.Priority = p
+		return err
+	}},
+	{"due", "Due", []string{"due", "due date", "deadline", "due on"}, func(t *Todo, v string, o csvOptions) error {
+		d, err := parseCSVDate(v, o.dateLayout)
+		t.Due = twDay(d)
+		return err
+	}},
+	{"defer", "Defer until", []string{"defer", "defer until", "start", "start date", "wait", "scheduled"}, func(t *Todo, v string, o csvOptions) error {
+		d, err := parseCSVDate(v, o.dateLayout)
+		t.DeferUntil = twDay(d)
+		return err
+	}},
+	{"tags", "Tags", []string{"tags", "tag", "labels", "label", "categories", "category"}, func(t *Todo, v string, _ csvOptions) error {
+		t.Tags = splitCSVList(v, "#")
+		return nil
+	}},
+	{"project", "Projects", []string{"project", "projects", "list"}, func(t *Todo, v string, _ csvOptions) error {
+		t.Projects = splitCSVList(v, "+")
+		return nil
+	}},
+	{"context", "Contexts", []string{"context", "contexts"}, func(t *Todo, v string, _ csvOptions) error {
+		t.Contexts = splitCSVList(v, "@")
+		return nil
+	}},
+	{"estimate", "Estimate", []string{"estimate", "estimated", "duration"}, func(t *Todo, v string, _ csvOptions) error {
+		if v == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(strings.ToLower(strings.TrimPrefix(v, "~")))
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		t.Estimate = d
+		return nil
+	}},
+	{"created", "Created", []string{"created", "created at", "created on", "date created", "entry", "added"}, func(t *Todo, v string, o csvOptions) error {
+		d, err := parseCSVDate(v, o.dateLayout)
+		t.CreatedAt = d
+		return err
+	}},
+	{"completed_at", "Completed at", []string{"completed at", "completed on", "done at", "finished", "end"}, func(t *Todo, v string, o csvOptions) error {
+		d, err := parseCSVDate(v, o.dateLayout)
+		t.CompletedAt = d
+		if !d.IsZero() {
+			t.Completed = true
+		}
+		return err
+	}},
+}
+
+func parseDone(v string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "false", "no", "n", "0", "open", "pending", "todo", "[ ]":
+		return false, nil
+	case "true", "yes", "y", "1", "x", "done", "completed", "complete", "closed", "[x]", "✓":
+		return true, nil
+	}
+	return false, fmt.Errorf("can't tell whether %q means done", v)
+}
+
+func parseCSVPriority(v string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "high", "h":
+		return "A", nil
+	case "medium", "med", "m", "normal":
+		return "B", nil
+	case "low", "l":
+		return "C", nil
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+		if n < 1 || n > 26 {
+			return "", fmt.Errorf("priority %d is out of range", n)
+		}
+		return string(rune('A' + n - 1)), nil
+	}
+	return parsePriority(v)
+}
+
+// parseCSVDate tries the configured layout, then ISO dates and times, then
+// the dates parseDate understands.
+func parseCSVDate(v, layout string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05"}
+	if layout != "" {
+		layouts = append([]string{layout}, layouts...)
+	}
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := parseDate(v, time.Now()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", v)
+}
+
+// splitCSVList splits on commas, semicolons, pipes and spaces and strips
+// a leading marker like "#" from each item.
+func splitCSVList(v, marker string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ';' || r == '|' || r == ' '
+	}) {
+		if item = strings.TrimPrefix(item, marker); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+type csvRow struct {
+	line   int
+	fields []string
+}
+
+// csvTable is a file read for import. Rows the reader can't make sense of
+// at all end up in problems with their line number.
+type csvTable struct {
+	header   []string
+	rows     []csvRow
+	problems []string
+}
+
+func readCSVTable(r io.Reader, comma string) (csvTable, error) {
+	var t csvTable
+	sep := []rune(comma)
+	if len(sep) != 1 {
+		return t, fmt.Errorf("--comma must be a single character")
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = sep[0]
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			t.problems = append(t.problems, parseErr.Error())
+			continue
+		}
+		if err != nil {
+			return t, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if t.header == nil {
+			t.header = record
+			continue
+		}
+		t.rows = append(t.rows, csvRow{line, record})
+	}
+	if t.header == nil {
+		return t, fmt.Errorf("the file is empty")
+	}
+	return t, nil
+}
+
+// csvMapping holds the column index for each of csvFields, or -1.
+type csvMapping []int
+
+// guessCSVMapping matches header names against each field's synonyms,
+// first exactly and then as a word in a longer name like "Task Name".
+func guessCSVMapping(header []string) csvMapping {
+	mapping := make(csvMapping, len(csvFields))
+	for i := range mapping {
+		mapping[i] = -1
+	}
+	used := map[int]bool{}
+	matches := []func(name, synonym string) bool{
+		func(name, synonym string) bool { return name == synonym },
+		func(name, synonym string) bool {
+			return !strings.Contains(synonym, " ") && hasTag(strings.Fields(name), synonym)
+		},
+	}
+	for _, match := range matches {
+		for i, f := range csvFields {
+			if mapping[i] >= 0 {
+				continue
+			}
+		columns:
+			for col, name := range header {
+				name = strings.ToLower(strings.TrimSpace(name))
+				for _, synonym := range f.synonyms {
+					if !used[col] && match(name, synonym) {
+						mapping[i] = col
+						used[col] = true
+						break columns
+					}
+				}
+			}
+		}
+	}
+	return mapping
+}
+
+// parseCSVMapping reads --map. Fields not named keep the guessed column.
+func parseCSVMapping(spec string, header []string) (csvMapping, error) {
+	mapping := guessCSVMapping(header)
+	for _, pair := range strings.Split(spec, ",") {
+		name, column, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("--map: expected FIELD=COLUMN, got %q", pair)
+		}
+		field := -1
+		for i, f := range csvFields {
+			if f.name == strings.TrimSpace(strings.ToLower(name)) {
+				field = i
+			}
+		}
+		if field < 0 {
+			var names []string
+			for _, f := range csvFields {
+				names = append(names, f.name)
+			}
+			return nil, fmt.Errorf("--map: unknown field %q (known: %s)", name, strings.Join(names, ", "))
+		}
+
+		col := -1
+		column = strings.TrimSpace(column)
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), column) {
+				col = i
+			}
+		}
+		if n, err := strconv.Atoi(column); col < 0 && err == nil && n >= 1 && n <= len(header) {
+			col = n - 1
+		}
+		if col < 0 && column != "" {
+			return nil, fmt.Errorf("--map: there is no column %q", column)
+		}
+		mapping[field] = col
+	}
+	return mapping, nil
+}
+
+// convert turns the rows into todos. A row with any field that doesn't
+// parse is left out and described in problems.
+func (t csvTable) convert(mapping csvMapping, o csvOptions) ([]Todo, []string) {
+	var todos []Todo
+	problems := append([]string(nil), t.problems...)
+	for _, row := range t.rows {
+		var todo Todo
+		var errs []string
+		for i, f := range csvFields {
+			col := mapping[i]
+			if col < 0 {
+				continue
+			}
+			value := ""
+			if col < len(row.fields) {
+				value = strings.TrimSpace(row.fields[col])
+			}
+			if err := f.set(&todo, value, o); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", strings.ToLower(f.label), err))
+			}
+		}
+		if todo.Title == "" {
+			errs = append(errs, "no title")
+		}
+		if len(errs) > 0 {
+			problems = append(problems, fmt.Sprintf("line %d: %s", row.line, strings.Join(errs, "; ")))
+			continue
+		}
+		todos = append(todos, todo)
+	}
+	return todos, problems
+}
+
+// previewLine is a one-line summary of an imported todo.
+func previewLine(todo Todo) string {
+	s := "[ ] "
+	if todo.Completed {
+		s = "[✓] "
+	}
+	if todo.Priority != "" {
+		s += "(" + todo.Priority + ") "
+	}
+	s += withEstimate(todo.Title, todo.Estimate)
+	for _, tag := range todo.Tags {
+		s += " #" + tag
+	}
+	for _, project := range todo.Projects {
+		s += " +" + project
+	}
+	for _, context := range todo.Contexts {
+		s += " @" + context
+	}
+	if !todo.Due.IsZero() {
+		s += " due " + formatDate(todo.Due)
+	}
+	if n := len(todo.SubTodos); n > 0 {
+		s += fmt.Sprintf(" (%d sub-todos)", n)
+	}
+	return s
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func importCSV(path string, o csvOptions) error {
+	if path == "" || path == "-" {
+		return fmt.Errorf("usage: todo import --from csv [--map ...] FILE")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	table, err := readCSVTable(file, o.comma)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	var mapping csvMapping
+	switch {
+	case o.mapping != "":
+		if mapping, err = parseCSVMapping(o.mapping, table.header); err != nil {
+			return err
+		}
+	case !o.yes && isTerminal(os.Stdin) && isTerminal(os.Stdout):
+		result, err := tea.NewProgram(newMappingModel(table, o), tea.WithAltScreen()).Run()
+		if err != nil {
+			return err
+		}
+		mm := result.(mappingModel)
+		if !mm.confirmed {
+			fmt.Println("Import cancelled.")
+			return nil
+		}
+		mapping = mm.mapping
+		o.yes = true
+	default:
+		mapping = guessCSVMapping(table.header)
+	}
+
+	imported, problems := table.convert(mapping, o)
+
+	if !o.yes {
+		fmt.Println("Columns:")
+		for i, f := range csvFields {
+			if mapping[i] >= 0 {
+				fmt.Printf("  %-13s <- %s\n", f.label, table.header[mapping[i]])
+			}
+		}
+		fmt.Printf("\n%d todos would be imported", len(imported))
+		if len(imported) > 5 {
+			fmt.Print(", starting with")
+		}
+		fmt.Println(":")
+		for i := 0; i < len(imported) && i < 5; i++ {
+			fmt.Println("  " + previewLine(imported[i]))
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("\n%d rows can't be imported:\n", len(problems))
+		for _, p := range problems {
+			fmt.Println("  " + p)
+		}
+	}
+
+	if !o.yes {
+		fmt.Println("\nRun again with --yes to import, or use --map to change the columns.")
+		return nil
+	}
+
+	todos, err := loadTodos()
+	if err != nil {
+		return err
+	}
+	todos, added, _ := mergeImported(todos, imported, format{name: "CSV"})
+	if err := saveTodos(todos); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d todos.\n", added)
+	return nil
+}
+
+// mappingModel is the screen for choosing which column feeds each field,
+// with a live preview of the result.
+type mappingModel struct {
+	table     csvTable
+	opts      csvOptions
+	mapping   csvMapping
+	cursor    int
+	confirmed bool
+}
+
+func newMappingModel(table csvTable, o csvOptions) mappingModel {
+	return mappingModel{table: table, opts: o, mapping: guessCSVMapping(table.header)}
+}
+
+func (m mappingModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m mappingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	// Columns cycle through -1 (none) and every header column.
+	columns := len(m.table.header) + 1
+	switch key.String() {
+	case "ctrl+c", "esc", "q":
+		return m, tea.Quit
+	case "enter":
+		m.confirmed = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(csvFields)-1 {
+			m.cursor++
+		}
+	case "right", "l", " ":
+		m.mapping = append(csvMapping(nil), m.mapping...)
+		m.mapping[m.cursor] = (m.mapping[m.cursor]+2)%columns - 1
+	case "left", "h":
+		m.mapping = append(csvMapping(nil), m.mapping...)
+		m.mapping[m.cursor] = (m.mapping[m.cursor]+columns)%columns - 1
+	}
+	return m, nil
+}
+
+func (m mappingModel) View() string {
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	selected := lipgloss.NewStyle().Foreground(lipgloss.Color("229")).Background(lipgloss.Color("57"))
+
+	var sample []string
+	if len(m.table.rows) > 0 {
+		sample = m.table.rows[0].fields
+	}
+
+	s := titleStyle.Render("Map CSV columns") + "\n\n"
+	for i, f := range csvFields {
+		column, example := "(none)", ""
+		if col := m.mapping[i]; col >= 0 {
+			column = m.table.header[col]
+			if col < len(sample) {
+				example = sample[col]
+			}
+		}
+		line := fmt.Sprintf("%-13s ← %-20s", f.label, column)
+		if i == m.cursor {
+			line = selected.Render(line)
+		}
+		s += "  " + line + "  " + dim.Render(truncate(strings.Join(strings.Fields(example), " "), 40)) + "\n"
+	}
+
+	todos, problems := m.table.convert(m.mapping, m.opts)
+	s += fmt.Sprintf("\n%d of %d rows import", len(todos), len(m.table.rows))
+	if len(problems) > 0 {
+		s += fmt.Sprintf(", %d don't", len(problems))
+	}
+	s += ":\n"
+	for i := 0; i < len(todos) && i < 5; i++ {
+		s += "  " + previewLine(todos[i]) + "\n"
+	}
+	for i := 0; i < len(problems) && i < 3; i++ {
+		s += "  " + lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(problems[i]) + "\n"
+	}
+
+	s += "\n" + dim.Render("[↑/↓] field  [←/→] column  [enter] import  [esc] cancel")
+	return s
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// parseDate understands absolute dates (2006-01-02), "today", "tomorrow",
+// weekday names for the next such day, and offsets like "+3d", "+2w" or
+// "-1m". An empty string yields the zero time.
+func parseDate(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch s {
+	case "":
+		return time.Time{}, nil
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "next week":
+		return today.AddDate(0, 0, 7), nil
+	}
+
+	if wd, ok := parseWeekday(s); ok {
+		days := (int(wd) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), nil
+	}
+
+	if (s[0] == '+' || s[0] == '-') && len(s) > 2 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err == nil {
+			switch s[len(s)-1] {
+			case 'd':
+				return today.AddDate(0, 0, n), nil
+			case 'w':
+				return today.AddDate(0, 0, 7*n), nil
+			case 'm':
+				return today.AddDate(0, n, 0), nil
+			}
+		}
+	}
+
+	t, err := time.ParseInLocation(dateLayout, s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+	}
+	return t, nil
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateLayout)
+}
+
+// parseSince is parseDate for looking back: a weekday name means the most
+// recent such day (today included) rather than the next one.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	t, err := parseDate(s, now)
+	if err != nil {
+		return t, err
+	}
+	if _, ok := parseWeekday(strings.ToLower(strings.TrimSpace(s))); ok {
+		t = t.AddDate(0, 0, -7)
+	}
+	return t, nil
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			return wd, true
+		}
+	}
+	return 0, false
+}
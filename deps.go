@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// openBlockers returns the IDs of the todos blocking this one that are
+// not done yet.
+func (m *model) openBlockers(todo Todo) []int {
+	var open []int
+	for _, id := range todo.BlockedBy {
+		if i := m.todoIndex(id); i >= 0 && !m.todos[i].Completed {
+			open = append(open, id)
+		}
+	}
+	return open
+}
+
+// dependents returns the todos that list id as a blocker.
+func (m *model) dependents(id int) []Todo {
+	var deps []Todo
+	for _, todo := range m.todos {
+		for _, blocker := range todo.BlockedBy {
+			if blocker == id {
+				deps = append(deps, todo)
+				break
+			}
+		}
+	}
+	return deps
+}
+
+// parseBlockers reads a list like "2, #5 7" into todo IDs.
+func parseBlockers(value string) ([]int, error) {
+	var ids []int
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, field := range fields {
+		id, err := strconv.Atoi(strings.TrimPrefix(field, "#"))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a todo number", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// setBlockers replaces the blockers of a todo, refusing unknown IDs and
+// links that would make a todo wait on itself.
+func (m *model) setBlockers(id int, blockers []int) error {
+	seen := map[int]bool{}
+	var unique []int
+	for _, blocker := range blockers {
+		if blocker == id {
+			return fmt.Errorf("a todo cannot block itself")
+		}
+		if m.todoIndex(blocker) < 0 {
+			return fmt.Errorf("there is no todo #%d", blocker)
+		}
+		if !seen[blocker] {
+			seen[blocker] = true
+			unique = append(unique, blocker)
+		}
+	}
+	sort.Ints(unique)
+
+	for _, blocker := range unique {
+		if path := m.dependencyPath(blocker, id); path != nil {
+			return fmt.Errorf("cycle: %s", formatTodoRefs(append([]int{id}, path...)))
+		}
+	}
+
+	i := m.todoIndex(id)
+	m.todos[i].BlockedBy = unique
+	m.save()
+	m.updateTable()
+	return nil
+}
+
+// dependencyPath returns the chain of blockers leading from one todo to
+// another, or nil if from does not (transitively) wait on to.
+func (m *model) dependencyPath(from, to int) []int {
+	visited := map[int]bool{}
+	var walk func(id int) []int
+	walk = func(id int) []int {
+		if id == to {
+			return []int{id}
+		}
+		if visited[id] {
+			return nil
+		}
+		visited[id] = true
+
+		i := m.todoIndex(id)
+		if i < 0 {
+			return nil
+		}
+		for _, blocker := range m.todos[i].BlockedBy {
+			if path := walk(blocker); path != nil {
+				return append([]int{id}, path...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
+
+// remapBlockers rewrites blocker IDs after todos are renumbered, dropping
+// links to todos that no longer exist.
+func remapBlockers(todos []Todo, newIDs map[int]int) {
+	for i := range todos {
+		var blockers []int
+		for _, blocker := range todos[i].BlockedBy {
+			if id, ok := newIDs[blocker]; ok {
+				blockers = append(blockers, id)
+			}
+		}
+		todos[i].BlockedBy = blockers
+	}
+}
+
+func formatTodoList(ids []int) string {
+	refs := make([]string, len(ids))
+	for i, id := range ids {
+		refs[i] = "#" + strconv.Itoa(id)
+	}
+	return strings.Join(refs, ", ")
+}
+
+func formatTodoRefs(ids []int) string {
+	refs := make([]string, len(ids))
+	for i, id := range ids {
+		refs[i] = "#" + strconv.Itoa(id)
+	}
+	return strings.Join(refs, " → ")
+}
+
+// dimCell renders text faint inside a table cell of the given width. The
+// table truncates cells by counting escape codes as visible characters,
+// so the text is shortened up front to leave room for them. Only the
+// intensity is reset afterwards so a selected row keeps its background.
+func dimCell(text string, width int) string {
+	const on, off = "\x1b[2m", "\x1b[22m"
+	room := width - runewidth.StringWidth(on+off)
+	if room < 1 {
+		return text
+	}
+	return on + runewidth.Truncate(text, room, "…") + off
+}
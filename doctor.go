@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// doctorCommand reports every problem in the todo file and, with --fix or
+// --quarantine, repairs it so it loads again.
+func doctorCommand(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "clear values that don't parse, renumber IDs and drop missing blockers")
+	quarantine := fs.Bool("quarantine", false, "move rows with problems to "+filepath.Base(quarantineFile())+" instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *fix && *quarantine {
+		return fmt.Errorf("use either --fix or --quarantine")
+	}
+
+	// A JSON file or a journal can only be checked; its errors point at
+	// what to fix by hand.
+	if _, ok := storeFor(todoFile).(csvStore); !ok {
+		if _, err := loadTodos(); err != nil {
+			return err
+		}
+		fmt.Printf("No problems found in %s.\n", todoFile)
+		return nil
+	}
+
+	f, err := readTodoFile(todoFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("There is no todo file at %s yet.\n", todoFile)
+			return nil
+		}
+		return err
+	}
+
+	var problems []fieldProblem
+	for _, row := range f.rows {
+		problems = append(problems, row.problems...)
+	}
+	if len(problems) == 0 {
+		fmt.Printf("No problems found in %s (schema version %d).\n", todoFile, f.version)
+		return nil
+	}
+
+	if !*fix && !*quarantine {
+		for _, p := range problems {
+			fmt.Println(p.Error())
+		}
+		fmt.Printf("\n%d problems. Run \"todo doctor --fix\" to repair them, or \"todo doctor --quarantine\" to move the rows aside.\n", len(problems))
+		return nil
+	}
+
+	var todos []Todo
+	var moved []todoRow
+	for _, row := range f.rows {
+		switch {
+		case len(row.problems) == 0:
+			todos = append(todos, row.todo)
+		case *quarantine || row.broken:
+			for _, p := range row.problems {
+				fmt.Printf("%s (moved to %s)\n", p.Error(), filepath.Base(quarantineFile()))
+			}
+			moved = append(moved, row)
+		default:
+			todos = append(todos, repairRow(row))
+		}
+	}
+
+	backup := todoFile + ".doctor.bak"
+	if err := copyFile(todoFile, backup); err != nil {
+		return err
+	}
+	if err := appendQuarantine(f.header, moved); err != nil {
+		return err
+	}
+	for v := f.version; v < schemaVersion; v++ {
+		migrations[v](todos)
+	}
+	repairTodos(todos)
+	if err := saveTodos(todos); err != nil {
+		return err
+	}
+	fmt.Printf("\nSaved %d todos; the previous file is at %s.\n", len(todos), backup)
+	return nil
+}
+
+func quarantineFile() string {
+	return strings.TrimSuffix(todoFile, filepath.Ext(todoFile)) + ".quarantine.csv"
+}
+
+// repairRow keeps a row whose fields could be split, with the values that
+// didn't parse cleared. Each cleared value is kept in a note.
+func repairRow(row todoRow) Todo {
+	todo := row.todo
+	for _, p := range row.problems {
+		action := "cleared"
+		switch p.field {
+		case "":
+			action = "kept what could be read"
+		case "ID", "UID":
+			action = "assigned a new one"
+		case "BlockedBy":
+			action = "dropped"
+		default:
+			if p.value != "" {
+				logActivity(&todo, activityNote, fmt.Sprintf("todo doctor cleared %s %q: %v", p.field, p.value, p.err))
+			}
+		}
+		fmt.Printf("%s (%s)\n", p.Error(), action)
+	}
+	return todo
+}
+
+// repairTodos numbers the todos 1..n in position order, the way deleting
+// does, and gives out new UIDs where one is missing or taken. Blockers
+// that no longer exist are dropped, and rows that lost their position go
+// last.
+func repairTodos(todos []Todo) {
+	last := func(p int) bool { return p <= 0 }
+	sort.SliceStable(todos, func(i, j int) bool {
+		pi, pj := todos[i].Position, todos[j].Position
+		if last(pi) || last(pj) {
+			return !last(pi) && last(pj)
+		}
+		return pi < pj
+	})
+	newIDs := map[int]int{}
+	for i := range todos {
+		if _, ok := newIDs[todos[i].ID]; !ok && todos[i].ID > 0 {
+			newIDs[todos[i].ID] = i + 1
+		}
+		todos[i].ID = i + 1
+	}
+	remapBlockers(todos, newIDs)
+	normalizePositions(todos)
+
+	uids := map[string]bool{}
+	for i := range todos {
+		if todos[i].UID == "" || uids[todos[i].UID] {
+			todos[i].UID = newUID()
+		}
+		uids[todos[i].UID] = true
+	}
+}
+
+// appendQuarantine adds the rows, exactly as they were in the file, to the
+// quarantine file, starting it with the header if it is new.
+func appendQuarantine(header string, rows []todoRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	path := quarantineFile()
+	_, statErr := os.Stat(path)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if os.IsNotExist(statErr) {
+		b.WriteString(header)
+	}
+	for _, row := range rows {
+		b.WriteString(row.raw)
+		if !strings.HasSuffix(row.raw, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	if _, err := file.WriteString(b.String()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func copyFile(from, to string) error {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(to, data, 0644)
+}
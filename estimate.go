@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// splitEstimate pulls a "~2h" style estimate out of a title. Any Go
+// duration works ("~45m", "~1h30m", "~1.5h"); the last one wins.
+func splitEstimate(title string) (string, time.Duration) {
+	var words []string
+	var estimate time.Duration
+	for _, word := range strings.Fields(title) {
+		if strings.HasPrefix(word, "~") {
+			if d, err := time.ParseDuration(strings.ToLower(word[1:])); err == nil && d > 0 {
+				estimate = d
+				continue
+			}
+		}
+		words = append(words, word)
+	}
+	if estimate == 0 {
+		return title, 0
+	}
+	return strings.Join(words, " "), estimate
+}
+
+// withEstimate is the inverse of splitEstimate, for putting a title back
+// into an input field.
+func withEstimate(title string, estimate time.Duration) string {
+	if estimate <= 0 {
+		return title
+	}
+	return title + " ~" + exactEstimate(estimate)
+}
+
+// exactEstimate is formatEstimate for estimates that are saved or edited,
+// which must come back as they were: anything that isn't whole minutes
+// is written out in full, like "20s" or "1m30s".
+func exactEstimate(d time.Duration) string {
+	if d%time.Minute != 0 {
+		return d.String()
+	}
+	return formatEstimate(d)
+}
+
+func formatEstimate(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d / time.Hour)
+	mins := int(d/time.Minute) % 60
+	switch {
+	case h == 0:
+		return strconv.Itoa(mins) + "m"
+	case mins == 0:
+		return strconv.Itoa(h) + "h"
+	}
+	return fmt.Sprintf("%dh%dm", h, mins)
+}
+
+// todoEstimate is the todo's own estimate, or the sum of its sub-todos'
+// when it has none.
+func todoEstimate(todo Todo) time.Duration {
+	if todo.Estimate > 0 {
+		return todo.Estimate
+	}
+	return subTodosEstimate(todo.SubTodos)
+}
+
+func subTodosEstimate(subTodos []SubTodo) time.Duration {
+	var total time.Duration
+	for _, sub := range subTodos {
+		if sub.Estimate > 0 {
+			total += sub.Estimate
+		} else {
+			total += subTodosEstimate(sub.Children)
+		}
+	}
+	return total
+}
+
+func (m model) estimateSummary() string {
+	var total time.Duration
+	count := 0
+	for _, todo := range m.todos {
+		if !m.matchesFilter(todo) {
+			continue
+		}
+		if d := todoEstimate(todo); d > 0 {
+			total += d
+			count++
+		}
+	}
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Estimated: %s across %d todos", formatEstimate(total), count)
+}
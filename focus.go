@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type focusPhase int
+
+const (
+	focusWork focusPhase = iota
+	focusBreak
+)
+
+// focusSession is the pomodoro running in focus mode. While paused,
+// remaining holds the time left; otherwise endsAt is authoritative.
+type focusSession struct {
+	todoID    int
+	phase     focusPhase
+	endsAt    time.Time
+	paused    bool
+	remaining time.Duration
+	subIdx    int
+}
+
+func (m *model) startFocus(id int) {
+	m.focus = focusSession{
+		todoID: id,
+		phase:  focusWork,
+		endsAt: time.Now().Add(time.Duration(cfg.PomodoroWork) * time.Minute),
+	}
+	m.mode = focusView
+}
+
+func (f focusSession) timeLeft(now time.Time) time.Duration {
+	if f.paused {
+		return f.remaining
+	}
+	if left := f.endsAt.Sub(now); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// advanceFocus moves to the next phase once the countdown runs out. The
+// session keeps running behind modals such as the cascade prompt.
+func (m *model) advanceFocus(now time.Time) {
+	if m.focus.todoID == 0 || m.focus.paused || now.Before(m.focus.endsAt) {
+		return
+	}
+	m.nextFocusPhase(now, true)
+}
+
+// nextFocusPhase switches between work and break. completed says whether
+// the phase ran its full length, which for a work phase logs a pomodoro
+// against the todo.
+func (m *model) nextFocusPhase(now time.Time, completed bool) {
+	length := cfg.PomodoroWork
+	if m.focus.phase == focusWork {
+		if i := m.todoIndex(m.focus.todoID); completed && i >= 0 {
+			m.todos[i].Pomodoros = append(m.todos[i].Pomodoros, now)
+			m.saveTracking()
+		}
+		m.focus.phase = focusBreak
+		length = cfg.PomodoroBreak
+	} else {
+		m.focus.phase = focusWork
+	}
+
+	m.focus.endsAt = now.Add(time.Duration(length) * time.Minute)
+	m.focus.remaining = time.Duration(length) * time.Minute
+}
+
+// focusOpenSubTodos lists the unchecked sub-todos of the focused todo.
+func (m *model) focusOpenSubTodos() []subTodoRef {
+	i := m.todoIndex(m.focus.todoID)
+	if i < 0 {
+		return nil
+	}
+	var open []subTodoRef
+	for _, ref := range visibleSubTodos(m.todos[i].SubTodos) {
+		if !subTodoAt(&m.todos[i].SubTodos, ref.path).Completed {
+			open = append(open, ref)
+		}
+	}
+	return open
+}
+
+func (m model) handleFocusKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	open := m.focusOpenSubTodos()
+
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tableView
+		m.focus = focusSession{}
+	case "p":
+		now := time.Now()
+		if m.focus.paused {
+			m.focus.endsAt = now.Add(m.focus.remaining)
+		} else {
+			m.focus.remaining = m.focus.timeLeft(now)
+		}
+		m.focus.paused = !m.focus.paused
+	case "n":
+		m.focus.paused = false
+		m.nextFocusPhase(time.Now(), false)
+	case "up":
+		if m.focus.subIdx > 0 {
+			m.focus.subIdx--
+		}
+	case "down":
+		if m.focus.subIdx < len(open)-1 {
+			m.focus.subIdx++
+		}
+	case " ":
+		if m.focus.subIdx < len(open) {
+			m.toggleSubTodoAt(m.focus.todoID, open[m.focus.subIdx].path)
+			if left := len(m.focusOpenSubTodos()); m.focus.subIdx >= left && left > 0 {
+				m.focus.subIdx = left - 1
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderFocusView() string {
+	i := m.todoIndex(m.focus.todoID)
+	if i < 0 {
+		return "No todo selected"
+	}
+	todo := m.todos[i]
+
+	phase := "Focus"
+	color := lipgloss.Color("205")
+	if m.focus.phase == focusBreak {
+		phase = "Break"
+		color = lipgloss.Color("42")
+	}
+	if m.focus.paused {
+		phase += " (paused)"
+	}
+
+	left := m.focus.timeLeft(time.Now()).Round(time.Second)
+	clock := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(color).
+		Render(fmt.Sprintf("%s  %02d:%02d", phase, int(left.Minutes()), int(left.Seconds())%60))
+
+	content := titleStyle.Render(todo.Title) + "\n\n"
+	content += clock + "\n\n"
+
+	open := m.focusOpenSubTodos()
+	if len(open) > 0 {
+		for idx, ref := range open {
+			sub := subTodoAt(&todo.SubTodos, ref.path)
+			line := fmt.Sprintf("%s[ ] %s", strings.Repeat("  ", ref.depth), withEstimate(sub.Title, sub.Estimate))
+			if idx == m.focus.subIdx {
+				line = lipgloss.NewStyle().
+					Background(lipgloss.Color("57")).
+					Foreground(lipgloss.Color("229")).
+					Render(line)
+			}
+			content += line + "\n"
+		}
+		content += "\n"
+	} else if len(todo.SubTodos) > 0 {
+		content += completedStyle.Render("All sub-todos done!") + "\n\n"
+	}
+
+	today := 0
+	now := time.Now()
+	for _, p := range todo.Pomodoros {
+		if p.Year() == now.Year() && p.YearDay() == now.YearDay() {
+			today++
+		}
+	}
+	content += fmt.Sprintf("Pomodoros: %d today, %d total\n\n", today, len(todo.Pomodoros))
+
+	help := "[space] check sub  [↑↓] navigate  [p] pause  [n] skip phase  [esc] leave"
+	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(help)
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		lipgloss.NewStyle().Align(lipgloss.Center).Render(content),
+	)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// With "git": true in the config, every save is committed to the git
+// repository the todo file lives in, with a message that says what
+// changed. Only the todo file is ever staged, so the repository can hold
+// other files, such as dotfiles, too.
+
+// git runs git in the todo file's directory and returns its output.
+func git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", filepath.Dir(todoFile)}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return string(out), nil
+}
+
+// committed is the list as it was last loaded or saved, which the next
+// commit message is worked out against. Until it is set, saveAndCommit
+// reads the file instead.
+var committed []Todo
+var haveCommitted bool
+
+func rememberCommitted(todos []Todo) {
+	committed = cloneTodos(todos)
+	haveCommitted = true
+}
+
+// checkGitRepo makes sure the todo file's directory is in a git
+// repository. Creating one is left to the user, so a typo in "file" can't
+// turn some other directory into a repository.
+func checkGitRepo() error {
+	if _, err := git("rev-parse", "--is-inside-work-tree"); err != nil {
+		return fmt.Errorf("%s is not in a git repository; run \"git init\" in %s or turn off \"git\" in %s",
+			todoFile, filepath.Dir(todoFile), getConfigFilePath())
+	}
+	return nil
+}
+
+// saveAndCommit saves todos and commits the todo file. An empty message is
+// worked out from what changed since the file was last saved.
+func saveAndCommit(todos []Todo, message string) error {
+	if err := checkGitRepo(); err != nil {
+		return err
+	}
+	old, loadErr := committed, error(nil)
+	if !haveCommitted {
+		old, _, loadErr = storeFor(todoFile).load(todoFile)
+	}
+	if err := storeFor(todoFile).save(todoFile, todos); err != nil {
+		return err
+	}
+	rememberCommitted(todos)
+
+	if message == "" {
+		switch {
+		case os.IsNotExist(loadErr):
+			message = fmt.Sprintf("start the list with %d todos", len(todos))
+		case loadErr != nil:
+			message = "rewrite the list"
+		default:
+			message = commitMessage(old, todos)
+		}
+	}
+	if message == "" {
+		return nil
+	}
+
+	name := filepath.Base(todoFile)
+	if _, err := git("add", "--", name); err != nil {
+		return err
+	}
+	if out, _ := git("status", "--porcelain", "--", name); strings.TrimSpace(out) == "" {
+		return nil
+	}
+	_, err := git("commit", "-q", "-m", message, "--", name)
+	return err
+}
+
+// commitMessage describes the change from old to todos, like
+// "complete #4 Buy groceries". When several todos changed, the first line
+// sums up and the body lists each change.
+func commitMessage(old, todos []Todo) string {
+	if !uniqueUIDs(old) || !uniqueUIDs(todos) {
+		return "update the list"
+	}
+	before := map[string]Todo{}
+	for _, todo := range old {
+		before[todo.UID] = todo
+	}
+	after := map[string]Todo{}
+	for _, todo := range todos {
+		after[todo.UID] = todo
+	}
+
+	var lines []string
+	moved := ""
+	for _, ev := range diffTodos(old, todos) {
+		todo := after[ev.UID]
+		switch ev.Op {
+		case "add":
+			todo = after[ev.Todo.UID]
+			lines = append(lines, fmt.Sprintf("add #%d %s", todo.ID, todo.Title))
+		case "delete":
+			todo = before[ev.UID]
+			lines = append(lines, fmt.Sprintf("delete #%d %s", todo.ID, todo.Title))
+		case "toggle":
+			verb := "reopen"
+			if todo.Completed {
+				verb = "complete"
+			}
+			lines = append(lines, fmt.Sprintf("%s #%d %s", verb, todo.ID, todo.Title))
+		case "subtoggle":
+			verb := "uncheck"
+			if *ev.Completed {
+				verb = "check"
+			}
+			sub := subTodoAt(&todo.SubTodos, ev.Path)
+			lines = append(lines, fmt.Sprintf("%s %q in #%d %s", verb, sub.Title, todo.ID, todo.Title))
+		case "edit":
+			lines = append(lines, fmt.Sprintf("edit #%d %s", todo.ID, todo.Title))
+		case "order":
+			moved = "reorder the list"
+		case "renumber":
+			moved = "renumber the list"
+		}
+	}
+
+	switch len(lines) {
+	case 0:
+		return moved
+	case 1:
+		return lines[0]
+	}
+	return fmt.Sprintf("%s and %d more\n\n%s", lines[0], len(lines)-1, strings.Join(lines, "\n"))
+}
+
+// logCommand lists the commits that touched the todo file, newest first.
+func logCommand(args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	n := fs.Int("n", 20, "how many commits to show; 0 shows all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: todo log [-n N]")
+	}
+
+	if _, err := git("rev-parse", "--is-inside-work-tree"); err != nil {
+		fmt.Printf("%s has no history yet. Set \"git\": true in %s to record it.\n", todoFile, getConfigFilePath())
+		return nil
+	}
+	gitArgs := []string{"log", "--date=format:%Y-%m-%d %H:%M", "--format=%h  %ad  %s"}
+	if *n > 0 {
+		gitArgs = append(gitArgs, fmt.Sprintf("-%d", *n))
+	}
+	out, err := git(append(gitArgs, "--", filepath.Base(todoFile))...)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		fmt.Printf("%s has no history yet. Set \"git\": true in %s to record it.\n", todoFile, getConfigFilePath())
+		return nil
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// revertCommand puts the list back the way it was after REV and commits
+// that as a new change, so the history in between is kept.
+func revertCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: todo revert REV")
+	}
+	rev := args[0]
+	name := filepath.Base(todoFile)
+	data, err := git("show", rev+":./"+name)
+	if err != nil {
+		return err
+	}
+	subject, err := git("log", "-1", "--format=%h %s", rev)
+	if err != nil {
+		return err
+	}
+
+	// Read the old contents with the same loader, through a file with the
+	// same extension next to the real one.
+	tmp, err := os.CreateTemp(filepath.Dir(todoFile), "revert-*"+filepath.Ext(todoFile))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	todos, version, err := storeFor(tmp.Name()).load(tmp.Name())
+	delete(journals, tmp.Name())
+	if err != nil {
+		return fmt.Errorf("%s: %v", rev, err)
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todos[i].Position < todos[j].Position
+	})
+	normalizePositions(todos)
+	for v := version; v < schemaVersion; v++ {
+		migrations[v](todos)
+	}
+
+	if err := saveAndCommit(todos, "revert to "+strings.TrimSpace(subject)); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d todos as of %s", len(todos), subject)
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Revision is a snapshot of the editable parts of a todo, recorded when
+// the todo is created and each time it is edited.
+type Revision struct {
+	At          time.Time
+	Title       string
+	Description string
+	SubTodos    []SubTodo `json:",omitempty"`
+}
+
+func snapshotRevision(todo Todo) Revision {
+	return Revision{
+		At:          time.Now(),
+		Title:       withEstimate(todo.Title, todo.Estimate),
+		Description: todo.Description,
+		SubTodos:    cloneSubTodos(todo.SubTodos),
+	}
+}
+
+// recordRevision appends the todo's current content as a revision unless
+// it is identical to the latest one.
+func recordRevision(todo *Todo) {
+	rev := snapshotRevision(*todo)
+	if n := len(todo.Revisions); n > 0 && revisionText(todo.Revisions[n-1]) == revisionText(rev) {
+		return
+	}
+	todo.Revisions = append(todo.Revisions, rev)
+}
+
+// recordEditRevision records a revision of todo i after an edit made in
+// place. A todo without revisions yet first gets its content from before
+// the edit, taken from the last save.
+func (m *model) recordEditRevision(i int) {
+	if len(m.todos[i].Revisions) == 0 {
+		for _, before := range m.lastSaved {
+			if before.UID == m.todos[i].UID {
+				rev := snapshotRevision(before)
+				if !before.CreatedAt.IsZero() {
+					rev.At = before.CreatedAt
+				}
+				m.todos[i].Revisions = append(m.todos[i].Revisions, rev)
+			}
+		}
+	}
+	recordRevision(&m.todos[i])
+}
+
+// revisionText is the plain-text form revisions are diffed in.
+func revisionText(rev Revision) string {
+	var b strings.Builder
+	b.WriteString("Title: " + rev.Title + "\n")
+	if rev.Description != "" {
+		b.WriteString("\n" + rev.Description + "\n")
+	}
+	if len(rev.SubTodos) > 0 {
+		b.WriteString("\n")
+		for _, ref := range visibleSubTodos(expandAll(rev.SubTodos)) {
+			sub := subTodoAt(&rev.SubTodos, ref.path)
+			b.WriteString(strings.Repeat("  ", ref.depth) + subTodoCheckbox(*sub) + " " + withEstimate(sub.Title, sub.Estimate) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// expandAll returns a copy of the tree with every item unfolded, so the
+// whole tree is walked regardless of display state.
+func expandAll(subTodos []SubTodo) []SubTodo {
+	clone := cloneSubTodos(subTodos)
+	var walk func(list []SubTodo)
+	walk = func(list []SubTodo) {
+		for i := range list {
+			list[i].Collapsed = false
+			walk(list[i].Children)
+		}
+	}
+	walk(clone)
+	return clone
+}
+
+func (m *model) restoreRevision(id, rev int) {
+	i := m.todoIndex(id)
+	if i < 0 || rev < 0 || rev >= len(m.todos[i].Revisions) {
+		return
+	}
+	r := m.todos[i].Revisions[rev]
+
+	todo := &m.todos[i]
+	todo.Title, todo.Estimate = splitEstimate(r.Title)
+	todo.Description = r.Description
+	todo.SubTodos = cloneSubTodos(r.SubTodos)
+	recordRevision(todo)
+	logActivity(todo, activityEdited, fmt.Sprintf("restored revision %d", rev+1))
+
+	m.save()
+	m.updateTable()
+}
+
+func (m *model) startHistory(todo *Todo) {
+	if len(todo.Revisions) == 0 {
+		return
+	}
+	m.historyIdx = len(todo.Revisions) - 1
+	m.historyBase = -1
+	m.mode = historyView
+}
+
+// historyPair returns the two revisions being compared: the marked base
+// (or the one before the cursor) and the one under the cursor.
+func (m *model) historyPair() (int, int) {
+	base := m.historyBase
+	if base < 0 {
+		base = m.historyIdx - 1
+	}
+	return base, m.historyIdx
+}
+
+func (m model) handleHistoryKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	todo := m.getCurrentTodo()
+	if todo == nil {
+		m.mode = tableView
+		return m, nil
+	}
+	count := len(todo.Revisions)
+
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = detailView
+	case "up":
+		if m.historyIdx < count-1 {
+			m.historyIdx++
+		}
+	case "down":
+		if m.historyIdx > 0 {
+			m.historyIdx--
+		}
+	case " ":
+		if m.historyBase == m.historyIdx {
+			m.historyBase = -1
+		} else {
+			m.historyBase = m.historyIdx
+		}
+	case "r":
+		if m.historyIdx < count {
+			id, rev := todo.ID, m.historyIdx
+			m.askConfirm(fmt.Sprintf("Restore revision %d of \"%s\"?", rev+1, todo.Title),
+				func(m *model) {
+					m.restoreRevision(id, rev)
+					m.historyIdx = len(m.todos[m.todoIndex(id)].Revisions) - 1
+					m.historyBase = -1
+				}, nil)
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderHistoryView() string {
+	todo := m.getCurrentTodo()
+	if todo == nil {
+		return "No todo selected"
+	}
+
+	popupWidth := m.width - 10
+	if popupWidth > 100 {
+		popupWidth = 100
+	}
+	if popupWidth < 40 {
+		popupWidth = 40
+	}
+
+	content := titleStyle.Render("History: "+todo.Title) + "\n\n"
+
+	base, target := m.historyPair()
+	for rev := len(todo.Revisions) - 1; rev >= 0; rev-- {
+		marker := "  "
+		if rev == base {
+			marker = "◦ "
+		}
+		line := fmt.Sprintf("%sr%d  %s", marker, rev+1, todo.Revisions[rev].At.Format("Jan 2, 2006 15:04"))
+		if rev == len(todo.Revisions)-1 {
+			line += "  (latest)"
+		}
+		if rev == target {
+			line = lipgloss.NewStyle().
+				Background(lipgloss.Color("57")).
+				Foreground(lipgloss.Color("229")).
+				Render(line)
+		}
+		content += line + "\n"
+	}
+	content += "\n"
+
+	var diff string
+	if base < 0 {
+		diff = unifiedDiff("/dev/null", fmt.Sprintf("r%d", target+1),
+			nil, strings.Split(strings.TrimSuffix(revisionText(todo.Revisions[target]), "\n"), "\n"), 3)
+	} else {
+		diff = unifiedDiff(fmt.Sprintf("r%d", base+1), fmt.Sprintf("r%d", target+1),
+			strings.Split(strings.TrimSuffix(revisionText(todo.Revisions[base]), "\n"), "\n"),
+			strings.Split(strings.TrimSuffix(revisionText(todo.Revisions[target]), "\n"), "\n"), 3)
+	}
+	if diff == "" {
+		diff = "(no changes)"
+	}
+
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	hunk := lipgloss.NewStyle().Foreground(lipgloss.Color("62"))
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			line = lipgloss.NewStyle().Bold(true).Render(line)
+		case strings.HasPrefix(line, "@@"):
+			line = hunk.Render(line)
+		case strings.HasPrefix(line, "+"):
+			line = added.Render(line)
+		case strings.HasPrefix(line, "-"):
+			line = removed.Render(line)
+		}
+		content += line + "\n"
+	}
+
+	content += "\n"
+	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(
+		"[↑↓] revision  [space] set/clear compare base  [r] restore  [esc] back",
+	)
+
+	popup := popupStyle.Width(popupWidth).Render(content)
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		popup,
+	)
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// iCalendar (RFC 5545) export and import. Every todo becomes a VTODO and
+// every sub-todo a VTODO of its own, linked to its parent with RELATED-TO.
+// Sub-todos have no UID of their own, so theirs is derived from the todo's
+// UID and their title, which keeps it the same when sub-todos are moved.
+
+const (
+	icalDateTime = "20060102T150405Z"
+	icalLocal    = "20060102T150405"
+	icalDate     = "20060102"
+)
+
+func writeICal(w io.Writer, todos []Todo) error {
+	iw := &icalWriter{w: bufio.NewWriter(w)}
+	stamp := time.Now().UTC().Format(icalDateTime)
+
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//todo//EN")
+	for _, todo := range todos {
+		iw.line("BEGIN:VTODO")
+		iw.line("UID:" + escapeICal(todo.UID))
+		iw.line("DTSTAMP:" + stamp)
+		if !todo.CreatedAt.IsZero() {
+			iw.line("CREATED:" + todo.CreatedAt.UTC().Format(icalDateTime))
+		}
+		iw.line("SUMMARY:" + escapeICal(todo.Title))
+		if todo.Description != "" {
+			iw.line("DESCRIPTION:" + escapeICal(todo.Description))
+		}
+		iw.status(todo.Completed)
+		if !todo.CompletedAt.IsZero() {
+			iw.line("COMPLETED:" + todo.CompletedAt.UTC().Format(icalDateTime))
+		}
+		if !todo.DeferUntil.IsZero() {
+			iw.line("DTSTART;VALUE=DATE:" + todo.DeferUntil.Format(icalDate))
+		}
+		if !todo.Due.IsZero() {
+			iw.line("DUE;VALUE=DATE:" + todo.Due.Format(icalDate))
+		}
+		if todo.Priority != "" {
+			iw.line("PRIORITY:" + strconv.Itoa(icalPriority(todo.Priority)))
+		}
+		if len(todo.Tags) > 0 {
+			tags := make([]string, len(todo.Tags))
+			for i, tag := range todo.Tags {
+				tags[i] = escapeICal(tag)
+			}
+			iw.line("CATEGORIES:" + strings.Join(tags, ","))
+		}
+		iw.line("END:VTODO")
+		iw.subTodos(todo.UID, todo.SubTodos, stamp)
+	}
+	iw.line("END:VCALENDAR")
+
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+type icalWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line, folding it after 75 octets without
+// splitting a UTF-8 sequence.
+func (iw *icalWriter) line(s string) {
+	if iw.err != nil {
+		return
+	}
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		_, iw.err = iw.w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// The leading space of a continuation line counts too.
+		limit = 74
+	}
+	if iw.err == nil {
+		_, iw.err = iw.w.WriteString(s + "\r\n")
+	}
+}
+
+func (iw *icalWriter) status(completed bool) {
+	if completed {
+		iw.line("STATUS:COMPLETED")
+	} else {
+		iw.line("STATUS:NEEDS-ACTION")
+	}
+}
+
+func (iw *icalWriter) subTodos(parent string, subs []SubTodo, stamp string) {
+	for i, key := range subTodoKeys(subs) {
+		sub := subs[i]
+		sum := sha1.Sum([]byte(key))
+		uid := fmt.Sprintf("%s-%x", parent, sum[:8])
+		iw.line("BEGIN:VTODO")
+		iw.line("UID:" + escapeICal(uid))
+		iw.line("DTSTAMP:" + stamp)
+		iw.line("SUMMARY:" + escapeICal(sub.Title))
+		iw.status(sub.Completed)
+		iw.line("RELATED-TO:" + escapeICal(parent))
+		iw.line("END:VTODO")
+		iw.subTodos(uid, sub.Children, stamp)
+	}
+}
+
+func escapeICal(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+func unescapeICal(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitICalList splits a TEXT list like CATEGORIES on unescaped commas.
+func splitICalList(s string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, unescapeICal(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, unescapeICal(s[start:]))
+}
+
+// icalPriority maps A-I onto iCalendar's 1 (highest) to 9 (lowest); later
+// letters all become 9.
+func icalPriority(p string) int {
+	n := int(p[0]-'A') + 1
+	if n > 9 {
+		n = 9
+	}
+	return n
+}
+
+type icalProp struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICalLine splits an unfolded content line into its name, parameters
+// and raw value. Parameter values may be quoted and contain ':' or ';'.
+func parseICalLine(line string) (icalProp, error) {
+	prop := icalProp{params: map[string]string{}}
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return prop, fmt.Errorf("missing ':'")
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	prop.value = line[colon+1:]
+	if prop.name == "" {
+		return prop, fmt.Errorf("missing property name")
+	}
+	return prop, nil
+}
+
+// parseICalTime reads DATE and DATE-TIME values. Times without a zone are
+// taken as local, TZID included, since we keep no zone database of our own.
+func parseICalTime(prop icalProp) (time.Time, error) {
+	v := prop.value
+	switch {
+	case prop.params["VALUE"] == "DATE" || len(v) == len(icalDate):
+		return time.ParseInLocation(icalDate, v, time.Local)
+	case strings.HasSuffix(v, "Z"):
+		t, err := time.Parse(icalDateTime, v)
+		return t.Local(), err
+	default:
+		return time.ParseInLocation(icalLocal, v, time.Local)
+	}
+}
+
+func icalDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// icalItem is a VTODO before it is placed in the tree.
+type icalItem struct {
+	todo   Todo
+	parent string
+}
+
+func readICal(r io.Reader) ([]Todo, error) {
+	lines, err := unfoldICal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []icalItem
+	var cur *icalItem
+	// todoDepth is the depth of the open VTODO. Properties of components
+	// nested in it, such as a VALARM's DESCRIPTION, aren't the todo's.
+	depth, todoDepth := 0, 0
+	for _, l := range lines {
+		prop, err := parseICalLine(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", l.number, err)
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			depth++
+			if strings.EqualFold(prop.value, "VTODO") {
+				if cur != nil {
+					return nil, fmt.Errorf("line %d: VTODO inside VTODO", l.number)
+				}
+				cur = &icalItem{}
+				todoDepth = depth
+			}
+			continue
+		case "END":
+			if strings.EqualFold(prop.value, "VTODO") && cur != nil && depth == todoDepth {
+				items = append(items, *cur)
+				cur = nil
+			}
+			depth--
+			continue
+		}
+		if cur == nil || depth != todoDepth {
+			continue
+		}
+
+		if err := cur.set(prop); err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", l.number, prop.name, err)
+		}
+	}
+	if depth != 0 || cur != nil {
+		return nil, fmt.Errorf("unexpected end of file inside a component")
+	}
+
+	return buildICalTree(items), nil
+}
+
+func (item *icalItem) set(prop icalProp) error {
+	todo := &item.todo
+	switch prop.name {
+	case "UID":
+		todo.UID = unescapeICal(prop.value)
+	case "SUMMARY":
+		todo.Title = unescapeICal(prop.value)
+	case "DESCRIPTION":
+		todo.Description = unescapeICal(prop.value)
+	case "STATUS":
+		todo.Completed = strings.EqualFold(prop.value, "COMPLETED")
+	case "RELATED-TO":
+		if reltype := prop.params["RELTYPE"]; reltype == "" || strings.EqualFold(reltype, "PARENT") {
+			item.parent = unescapeICal(prop.value)
+		}
+	case "CATEGORIES":
+		todo.Tags = append(todo.Tags, splitICalList(prop.value)...)
+	case "PRIORITY":
+		n, err := strconv.Atoi(prop.value)
+		if err != nil || n < 0 || n > 9 {
+			return fmt.Errorf("invalid priority %q", prop.value)
+		}
+		todo.Priority = ""
+		if n > 0 {
+			todo.Priority = string(rune('A' + n - 1))
+		}
+	case "CREATED", "COMPLETED", "DUE", "DTSTART":
+		t, err := parseICalTime(prop)
+		if err != nil {
+			return fmt.Errorf("invalid date %q", prop.value)
+		}
+		switch prop.name {
+		case "CREATED":
+			todo.CreatedAt = t
+		case "COMPLETED":
+			todo.CompletedAt = t
+			todo.Completed = true
+		case "DUE":
+			todo.Due = icalDay(t)
+		case "DTSTART":
+			todo.DeferUntil = icalDay(t)
+		}
+	}
+	return nil
+}
+
+// buildICalTree turns VTODOs whose parent is in the file into sub-todos
+// of that parent. The rest become todos, and so does the first VTODO of a
+// chain of parents that leads back to itself.
+func buildICalTree(items []icalItem) []Todo {
+	children := map[string][]int{}
+	known := map[string]bool{}
+	for _, item := range items {
+		if item.todo.UID != "" {
+			known[item.todo.UID] = true
+		}
+	}
+	var roots []int
+	for i, item := range items {
+		if item.parent != "" && known[item.parent] && item.parent != item.todo.UID {
+			children[item.parent] = append(children[item.parent], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	visited := map[string]bool{}
+	var subTodos func(uid string) []SubTodo
+	subTodos = func(uid string) []SubTodo {
+		if visited[uid] {
+			return nil
+		}
+		visited[uid] = true
+		var subs []SubTodo
+		for _, i := range children[uid] {
+			subs = append(subs, SubTodo{
+				Title:     items[i].todo.Title,
+				Completed: items[i].todo.Completed,
+				Children:  subTodos(items[i].todo.UID),
+			})
+		}
+		return subs
+	}
+
+	var todos []Todo
+	addRoot := func(i int) {
+		todo := items[i].todo
+		if todo.UID != "" {
+			todo.SubTodos = subTodos(todo.UID)
+			renumberSubTodos(todo.SubTodos)
+		}
+		todos = append(todos, todo)
+	}
+	for _, i := range roots {
+		addRoot(i)
+	}
+	// Whatever wasn't reached from a root is in a cycle. Cut each one at
+	// its first VTODO.
+	for i, item := range items {
+		if item.todo.UID == "" || visited[item.todo.UID] {
+			continue
+		}
+		siblings := children[item.parent]
+		children[item.parent] = slices.DeleteFunc(siblings, func(j int) bool { return j == i })
+		addRoot(i)
+	}
+	return todos
+}
+
+type icalLine struct {
+	number int
+	text   string
+}
+
+// unfoldICal joins continuation lines, which start with a space or tab,
+// onto the line before them.
+func unfoldICal(r io.Reader) ([]icalLine, error) {
+	var lines []icalLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(text) > 0 && (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		lines = append(lines, icalLine{number, text})
+	}
+	return lines, scanner.Err()
+}
+
+// mergeICal copies the fields an iCalendar file carries onto an existing
+// todo.
+func mergeICal(dst *Todo, src Todo) {
+	dst.Title = src.Title
+	dst.Description = src.Description
+	dst.Completed = src.Completed
+	dst.CompletedAt = src.CompletedAt
+	if dst.Completed && dst.CompletedAt.IsZero() {
+		dst.CompletedAt = time.Now()
+	}
+	dst.Due = src.Due
+	dst.DeferUntil = src.DeferUntil
+	dst.Priority = src.Priority
+	dst.Tags = src.Tags
+	dst.SubTodos = mergeICalSubTodos(dst.SubTodos, src.SubTodos)
+	renumberSubTodos(dst.SubTodos)
+}
+
+// mergeICalSubTodos takes the sub-todos, their order and their state from
+// src, but keeps the estimate and folding of the ones dst already had.
+// Sub-todos are matched by title, like in sync.
+func mergeICalSubTodos(dst, src []SubTodo) []SubTodo {
+	inDst := keyIndex(subTodoKeys(dst))
+	var out []SubTodo
+	for i, key := range subTodoKeys(src) {
+		sub, ok := lookupSub(dst, inDst, key)
+		if !ok {
+			out = append(out, src[i])
+			continue
+		}
+		sub.Completed = src[i].Completed
+		sub.Children = mergeICalSubTodos(sub.Children, src[i].Children)
+		out = append(out, sub)
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestICalIgnoresAlarmProperties(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VTODO",
+		"UID:a",
+		"SUMMARY:Pay rent",
+		"DESCRIPTION:Bank transfer",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Reminder",
+		"END:VALARM",
+		"PRIORITY:1",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	todos, err := readICal(strings.NewReader(ics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 {
+		t.Fatalf("got %d todos, want 1", len(todos))
+	}
+	if todos[0].Description != "Bank transfer" {
+		t.Errorf("description is %q, want %q", todos[0].Description, "Bank transfer")
+	}
+	if todos[0].Priority != "A" {
+		t.Errorf("priority is %q, want A", todos[0].Priority)
+	}
+}
+
+func TestICalKeepsTodosInAParentCycle(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VTODO", "UID:a", "SUMMARY:A", "RELATED-TO:b", "END:VTODO",
+		"BEGIN:VTODO", "UID:b", "SUMMARY:B", "RELATED-TO:a", "END:VTODO",
+		"BEGIN:VTODO", "UID:c", "SUMMARY:C", "END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	todos, err := readICal(strings.NewReader(ics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 2 || todos[0].Title != "C" || todos[1].Title != "A" {
+		t.Fatalf("got %+v, want C and A", todos)
+	}
+	if subs := todos[1].SubTodos; len(subs) != 1 || subs[0].Title != "B" || len(subs[0].Children) != 0 {
+		t.Errorf("A has sub-todos %+v, want just B", subs)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// format converts todos to and from another tool's file format. Formats
+// that carry our UID set merge, which copies the fields the format knows
+// about onto a todo that is already in the list.
+type format struct {
+	name   string
+	decode func(r io.Reader) ([]Todo, error)
+	encode func(w io.Writer, todos []Todo) error
+	merge  func(dst *Todo, src Todo)
+}
+
+var formats = map[string]format{
+	"todotxt":     {"todo.txt", readTodoTxt, writeTodoTxt, nil},
+	"ical":        {"iCalendar", readICal, writeICal, mergeICal},
+	"markdown":    {"markdown", readMarkdown, writeMarkdown, nil},
+	"taskwarrior": {"Taskwarrior", readTaskwarrior, writeTaskwarrior, mergeTaskwarrior},
+}
+
+func formatNames() string {
+	var names []string
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func lookupFormat(name string) (format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return f, fmt.Errorf("unknown format %q (known: %s)", name, formatNames())
+	}
+	return f, nil
+}
+
+// importCommand reads todos from FILE, or stdin when it is missing or "-",
+// and adds them to the list.
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := fs.String("from", "", "format of the file to import (csv, "+formatNames()+")")
+	var csvOpts csvOptions
+	csvOpts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || fs.NArg() > 1 {
+		return fmt.Errorf("usage: todo import --from FORMAT [FILE]")
+	}
+	if *from == "csv" {
+		return importCSV(fs.Arg(0), csvOpts)
+	}
+	f, err := lookupFormat(*from)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	imported, err := f.decode(in)
+	if err != nil {
+		return err
+	}
+
+	todos, err := loadTodos()
+	if err != nil {
+		return err
+	}
+	todos, added, updated := mergeImported(todos, imported, f)
+	if err := saveTodos(todos); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d new and updated %d existing todos.\n", added, updated)
+	return nil
+}
+
+// exportCommand writes the whole list to FILE, or stdout when it is missing
+// or "-".
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	to := fs.String("to", "", "format to export to ("+formatNames()+")")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" || fs.NArg() > 1 {
+		return fmt.Errorf("usage: todo export --to FORMAT [FILE]")
+	}
+	f, err := lookupFormat(*to)
+	if err != nil {
+		return err
+	}
+
+	todos, err := loadTodos()
+	if err != nil {
+		return err
+	}
+	// Loading gives every todo a UID; this only covers rows whose UID
+	// was blanked by hand. Exporting never writes the todo file.
+	ensureUIDs(todos)
+
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := f.encode(file, todos); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	return f.encode(os.Stdout, todos)
+}
+
+// mergeImported updates the todos whose UID is already in the list and
+// numbers the rest after the existing ones, logging where they came from.
+func mergeImported(todos, imported []Todo, f format) ([]Todo, int, int) {
+	ensureUIDs(todos)
+	byUID := map[string]int{}
+	nextID := 1
+	for i, todo := range todos {
+		byUID[todo.UID] = i
+		// Taskwarrior only takes UUIDs, so todos with older UIDs went
+		// out under one derived from theirs.
+		if uuid := twUUID(todo.UID); uuid != todo.UID {
+			byUID[uuid] = i
+		}
+		if todo.ID >= nextID {
+			nextID = todo.ID + 1
+		}
+	}
+
+	added, updated := 0, 0
+	now := time.Now()
+	for _, todo := range imported {
+		if i, ok := byUID[todo.UID]; ok && f.merge != nil {
+			if len(todos[i].Revisions) == 0 {
+				recordRevision(&todos[i])
+			}
+			f.merge(&todos[i], todo)
+			logActivity(&todos[i], activityEdited, "updated from "+f.name)
+			recordRevision(&todos[i])
+			updated++
+			continue
+		}
+
+		if _, taken := byUID[todo.UID]; taken || todo.UID == "" {
+			todo.UID = newUID()
+		}
+		todo.ID = nextID
+		nextID++
+		todo.Position = len(todos) + 1
+		if todo.CreatedAt.IsZero() {
+			todo.CreatedAt = now
+		}
+		if todo.Completed && todo.CompletedAt.IsZero() {
+			todo.CompletedAt = now
+		}
+		logActivity(&todo, activityCreated, "imported from "+f.name)
+		recordRevision(&todo)
+		byUID[todo.UID] = len(todos)
+		todos = append(todos, todo)
+		added++
+	}
+	return todos, added, updated
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// journalStore appends one JSON line per change instead of rewriting the
+// whole list, so a save costs as much as the change did. Every so often
+// the journal is compacted into a single snapshot line. A crash can only
+// cut the last line short. If that line doesn't parse, load skips it and
+// the next save cuts it off before appending.
+//
+// A journal starts with a snapshot and is followed by events:
+//
+//	{"seq":1,"at":"…","op":"snapshot","schema":2,"todos":[…]}
+//	{"seq":2,"at":"…","op":"add","todo":{…}}
+//	{"seq":3,"at":"…","op":"toggle","uid":"…","completed":true,"completed_at":"…"}
+type journalStore struct{}
+
+// snapshotEvery is how many events a journal collects before save
+// compacts it.
+const snapshotEvery = 200
+
+type journalEvent struct {
+	Seq  int       `json:"seq"`
+	At   time.Time `json:"at"`
+	Op   string    `json:"op"`
+	UID  string    `json:"uid,omitempty"`
+	Todo *jsonTodo `json:"todo,omitempty"`
+
+	// snapshot
+	Schema int        `json:"schema,omitempty"`
+	Todos  []jsonTodo `json:"todos,omitempty"`
+
+	// toggle and subtoggle
+	Completed   *bool      `json:"completed,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Activity    []Activity `json:"activity,omitempty"`
+	Path        []int      `json:"path,omitempty"`
+
+	// order and renumber
+	Order []string       `json:"order,omitempty"`
+	IDs   map[string]int `json:"ids,omitempty"`
+}
+
+// journalState is what a journal held after it was last read or written;
+// save appends the difference between it and the new list. The file's size
+// and modification time tell whether another process has written to it
+// since.
+type journalState struct {
+	todos   []Todo
+	seq     int
+	events  int
+	size    int64
+	modTime time.Time
+
+	// valid is how much of the file load could read. Anything after it
+	// is a cut-short line that save removes before appending.
+	valid int64
+
+	// unterminated is set when the last line is complete but has no
+	// newline, so the next event has to start with one.
+	unterminated bool
+}
+
+// stamp records the file's size and modification time.
+func (st *journalState) stamp(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	st.size, st.modTime = info.Size(), info.ModTime()
+	return nil
+}
+
+// current reports whether the file is still the way st last saw it.
+func (st *journalState) current(info os.FileInfo) bool {
+	return info.Size() == st.size && info.ModTime().Equal(st.modTime)
+}
+
+var journals = map[string]*journalState{}
+
+func (journalStore) load(path string) ([]Todo, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var todos []Todo
+	st := &journalState{}
+	version := 0
+	offset := 0
+	for line := 1; offset < len(data); line++ {
+		end := bytes.IndexByte(data[offset:], '\n')
+		terminated := end >= 0
+		if !terminated {
+			end = len(data) - offset
+		}
+		var ev journalEvent
+		if err := json.Unmarshal(data[offset:offset+end], &ev); err != nil {
+			if !terminated || offset+end+1 == len(data) {
+				// The last write was cut short. Leave the file alone
+				// here, since load also serves commands that only read.
+				break
+			}
+			return nil, 0, fmt.Errorf("%s: line %d: %v", path, line, err)
+		}
+		st.unterminated = !terminated
+		offset += end + 1
+		st.valid = int64(min(offset, len(data)))
+
+		if line == 1 && ev.Op != "snapshot" {
+			return nil, 0, fmt.Errorf("%s: line 1: a journal must start with a snapshot", path)
+		}
+		if ev.Op == "snapshot" {
+			if ev.Schema < 1 || ev.Schema > schemaVersion {
+				return nil, 0, fmt.Errorf("%s: line %d: unsupported schema version %d", path, line, ev.Schema)
+			}
+			version = ev.Schema
+			st.events = 0
+		} else {
+			st.events++
+		}
+		if todos, err = applyEvent(todos, ev); err != nil {
+			return nil, 0, fmt.Errorf("%s: line %d: %v", path, line, err)
+		}
+		st.seq = ev.Seq
+	}
+	if version == 0 {
+		return nil, 0, fmt.Errorf("%s: the journal is empty", path)
+	}
+
+	for i := range todos {
+		todos[i].Position = i + 1
+	}
+	st.todos = cloneTodos(todos)
+	if err := st.stamp(path); err != nil {
+		return nil, 0, err
+	}
+	journals[path] = st
+	return todos, version, nil
+}
+
+// applyEvent replays one event onto the list.
+func applyEvent(todos []Todo, ev journalEvent) ([]Todo, error) {
+	if ev.Op == "snapshot" {
+		todos = []Todo{}
+		for _, jt := range ev.Todos {
+			todo, errs := jt.todo()
+			if len(errs) > 0 {
+				return nil, fmt.Errorf("%q: %v", jt.Title, errs[0])
+			}
+			todos = append(todos, todo)
+		}
+		return todos, nil
+	}
+	if ev.Op == "add" {
+		if ev.Todo == nil {
+			return nil, fmt.Errorf("add without a todo")
+		}
+		todo, errs := ev.Todo.todo()
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%q: %v", ev.Todo.Title, errs[0])
+		}
+		return append(todos, todo), nil
+	}
+	if ev.Op == "order" || ev.Op == "renumber" {
+		return reorderTodos(todos, ev)
+	}
+
+	i := indexOfUID(todos, ev.UID)
+	if i < 0 {
+		return nil, fmt.Errorf("%s: there is no todo %q", ev.Op, ev.UID)
+	}
+	switch ev.Op {
+	case "delete":
+		return append(todos[:i], todos[i+1:]...), nil
+	case "edit":
+		if ev.Todo == nil {
+			return nil, fmt.Errorf("edit without a todo")
+		}
+		todo, errs := ev.Todo.todo()
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%q: %v", ev.Todo.Title, errs[0])
+		}
+		todos[i] = todo
+	case "toggle":
+		if ev.Completed == nil {
+			return nil, fmt.Errorf("toggle without completed")
+		}
+		todos[i].Completed = *ev.Completed
+		todos[i].CompletedAt = time.Time{}
+		if ev.CompletedAt != nil {
+			todos[i].CompletedAt = *ev.CompletedAt
+		}
+		todos[i].Activity = append(todos[i].Activity, ev.Activity...)
+	case "subtoggle":
+		sub := subTodoAt(&todos[i].SubTodos, ev.Path)
+		if sub == nil || ev.Completed == nil {
+			return nil, fmt.Errorf("subtoggle: there is no sub-todo at %v", ev.Path)
+		}
+		sub.Completed = *ev.Completed
+	default:
+		return nil, fmt.Errorf("unknown event %q", ev.Op)
+	}
+	return todos, nil
+}
+
+func reorderTodos(todos []Todo, ev journalEvent) ([]Todo, error) {
+	for uid, id := range ev.IDs {
+		i := indexOfUID(todos, uid)
+		if i < 0 {
+			return nil, fmt.Errorf("%s: there is no todo %q", ev.Op, uid)
+		}
+		todos[i].ID = id
+	}
+	if ev.Op != "order" {
+		return todos, nil
+	}
+	if len(ev.Order) != len(todos) {
+		return nil, fmt.Errorf("order: lists %d of %d todos", len(ev.Order), len(todos))
+	}
+	ordered := make([]Todo, 0, len(todos))
+	for _, uid := range ev.Order {
+		i := indexOfUID(todos, uid)
+		if i < 0 {
+			return nil, fmt.Errorf("order: there is no todo %q", uid)
+		}
+		ordered = append(ordered, todos[i])
+	}
+	return ordered, nil
+}
+
+func uniqueUIDs(todos []Todo) bool {
+	seen := map[string]bool{}
+	for _, todo := range todos {
+		if seen[todo.UID] {
+			return false
+		}
+		seen[todo.UID] = true
+	}
+	return true
+}
+
+func indexOfUID(todos []Todo, uid string) int {
+	for i, todo := range todos {
+		if todo.UID == uid {
+			return i
+		}
+	}
+	return -1
+}
+
+// save appends the events that turn the journal's last state into todos,
+// or writes a fresh snapshot when the file is new or has collected enough
+// events.
+func (journalStore) save(path string, todos []Todo) error {
+	ensureUIDs(todos)
+	st := journals[path]
+	if info, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		st = nil
+	} else if st == nil || !st.current(info) {
+		// Someone else wrote to the journal since we read it. Start
+		// from what is there now, so every event applies to a todo
+		// the file still has.
+		if _, _, err := (journalStore{}).load(path); err != nil {
+			return err
+		}
+		st = journals[path]
+	}
+
+	if st == nil {
+		return writeSnapshot(path, &journalState{}, todos)
+	}
+	// Events find their todo by UID, so a list where two todos share
+	// one can only be written whole.
+	if !uniqueUIDs(todos) {
+		return writeSnapshot(path, st, todos)
+	}
+	events := diffTodos(st.todos, todos)
+	if len(events) == 0 {
+		return nil
+	}
+	if st.events+len(events) >= snapshotEvery {
+		return writeSnapshot(path, st, todos)
+	}
+
+	now := time.Now()
+	var buf bytes.Buffer
+	if st.unterminated {
+		buf.WriteByte('\n')
+	}
+	for _, ev := range events {
+		st.seq++
+		ev.Seq = st.seq
+		ev.At = now
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	// Cut off a half-written line, so the first event doesn't get
+	// appended to it.
+	if st.valid < st.size {
+		if err := os.Truncate(path, st.valid); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	st.events += len(events)
+	st.todos = cloneTodos(todos)
+	st.unterminated = false
+	return st.stamp(path)
+}
+
+// writeSnapshot replaces the journal with a single snapshot. It writes a
+// new file and renames it over the old one, so a crash leaves one or the
+// other.
+func writeSnapshot(path string, st *journalState, todos []Todo) error {
+	st.seq++
+	ev := journalEvent{Seq: st.seq, At: time.Now(), Op: "snapshot", Schema: schemaVersion, Todos: []jsonTodo{}}
+	for _, todo := range todos {
+		ev.Todos = append(ev.Todos, toJSONTodo(todo))
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	st.events = 0
+	st.todos = cloneTodos(todos)
+	st.unterminated = false
+	journals[path] = st
+	return st.stamp(path)
+}
+
+// diffTodos returns the events that turn old into new. Todos are matched by
+// UID; a change that only completes a todo or a sub-todo gets an event of
+// its own, anything else rewrites the todo.
+func diffTodos(old, new []Todo) []journalEvent {
+	var events []journalEvent
+	inNew := map[string]bool{}
+	for _, todo := range new {
+		inNew[todo.UID] = true
+	}
+	var order []string
+	oldByUID := map[string]Todo{}
+	for _, todo := range old {
+		if !inNew[todo.UID] {
+			events = append(events, journalEvent{Op: "delete", UID: todo.UID})
+			continue
+		}
+		oldByUID[todo.UID] = todo
+		order = append(order, todo.UID)
+	}
+
+	ids := map[string]int{}
+	for _, todo := range new {
+		o, ok := oldByUID[todo.UID]
+		if !ok {
+			jt := toJSONTodo(todo)
+			events = append(events, journalEvent{Op: "add", Todo: &jt})
+			order = append(order, todo.UID)
+			continue
+		}
+		if o.ID != todo.ID {
+			ids[todo.UID] = todo.ID
+		}
+		if ev, changed := changeEvent(o, todo); changed {
+			events = append(events, ev)
+		}
+	}
+
+	reordered := false
+	newOrder := make([]string, len(new))
+	for i, todo := range new {
+		newOrder[i] = todo.UID
+		reordered = reordered || order[i] != todo.UID
+	}
+	switch {
+	case reordered:
+		events = append(events, journalEvent{Op: "order", Order: newOrder, IDs: ids})
+	case len(ids) > 0:
+		events = append(events, journalEvent{Op: "renumber", IDs: ids})
+	}
+	return events
+}
+
+// changeEvent describes how o became todo, leaving out the ID and the
+// position, which diffTodos handles for the whole list.
+func changeEvent(o, todo Todo) (journalEvent, bool) {
+	if sameTodo(o, todo) {
+		return journalEvent{}, false
+	}
+
+	if o.Completed != todo.Completed && len(todo.Activity) >= len(o.Activity) {
+		rest := todo
+		rest.Completed, rest.CompletedAt = o.Completed, o.CompletedAt
+		rest.Activity = todo.Activity[:len(o.Activity)]
+		if sameTodo(o, rest) {
+			ev := journalEvent{Op: "toggle", UID: todo.UID, Completed: &todo.Completed, Activity: todo.Activity[len(o.Activity):]}
+			if !todo.CompletedAt.IsZero() {
+				ev.CompletedAt = &todo.CompletedAt
+			}
+			return ev, true
+		}
+	}
+
+	for _, path := range subTodoPaths(todo.SubTodos, nil) {
+		before := subTodoAt(&o.SubTodos, path)
+		after := subTodoAt(&todo.SubTodos, path)
+		if before == nil || before.Completed == after.Completed {
+			continue
+		}
+		rest := todo
+		rest.SubTodos = cloneSubTodos(todo.SubTodos)
+		subTodoAt(&rest.SubTodos, path).Completed = before.Completed
+		if sameTodo(o, rest) {
+			return journalEvent{Op: "subtoggle", UID: todo.UID, Completed: &after.Completed, Path: path}, true
+		}
+		break
+	}
+
+	jt := toJSONTodo(todo)
+	return journalEvent{Op: "edit", UID: todo.UID, Todo: &jt}, true
+}
+
+// sameTodo compares two todos as the journal would write them, which
+// ignores how times happen to be held in memory.
+func sameTodo(a, b Todo) bool {
+	a.ID, b.ID = 0, 0
+	ja, errA := json.Marshal(toJSONTodo(a))
+	jb, errB := json.Marshal(toJSONTodo(b))
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// subTodoPaths lists the path of every sub-todo, collapsed or not.
+func subTodoPaths(subTodos []SubTodo, prefix []int) [][]int {
+	var paths [][]int
+	for i, sub := range subTodos {
+		path := append(append([]int(nil), prefix...), i)
+		paths = append(paths, path)
+		paths = append(paths, subTodoPaths(sub.Children, path)...)
+	}
+	return paths
+}
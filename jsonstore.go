@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// jsonStore keeps the list as an indented JSON document meant to be read,
+// edited and diffed by hand: sub-todos nest as real objects, dates are
+// plain dates and estimates are written like "1h30m".
+type jsonStore struct{}
+
+type jsonFile struct {
+	Schema int        `json:"schema"`
+	Todos  []jsonTodo `json:"todos"`
+}
+
+type jsonTodo struct {
+	ID          int           `json:"id"`
+	UID         string        `json:"uid,omitempty"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Completed   bool          `json:"completed,omitempty"`
+	CreatedAt   *time.Time    `json:"created_at,omitempty"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+	Priority    string        `json:"priority,omitempty"`
+	Due         string        `json:"due,omitempty"`
+	DeferUntil  string        `json:"defer_until,omitempty"`
+	Recurrence  string        `json:"recurrence,omitempty"`
+	Archived    bool          `json:"archived,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Projects    []string      `json:"projects,omitempty"`
+	Contexts    []string      `json:"contexts,omitempty"`
+	BlockedBy   []int         `json:"blocked_by,omitempty"`
+	Estimate    string        `json:"estimate,omitempty"`
+	SubTodos    []jsonSubTodo `json:"sub_todos,omitempty"`
+	TimeEntries []TimeEntry   `json:"time_entries,omitempty"`
+	Pomodoros   []time.Time   `json:"pomodoros,omitempty"`
+	Activity    []Activity    `json:"activity,omitempty"`
+	Revisions   []Revision    `json:"revisions,omitempty"`
+}
+
+type jsonSubTodo struct {
+	Title     string        `json:"title"`
+	Completed bool          `json:"completed,omitempty"`
+	Collapsed bool          `json:"collapsed,omitempty"`
+	Estimate  string        `json:"estimate,omitempty"`
+	Children  []jsonSubTodo `json:"children,omitempty"`
+}
+
+func (jsonStore) save(path string, todos []Todo) error {
+	doc := jsonFile{Schema: schemaVersion, Todos: []jsonTodo{}}
+	for _, todo := range todos {
+		doc.Todos = append(doc.Todos, toJSONTodo(todo))
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// load is as strict as the CSV loader: unknown keys, values of the wrong
+// type and anything the CSV loader would reject are errors, reported with
+// the line or the todo they were found in.
+func (jsonStore) load(path string) ([]Todo, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseJSONTodos(path, data)
+}
+
+// parseJSONTodos does the work of load on data read from path.
+func parseJSONTodos(path string, data []byte) ([]Todo, int, error) {
+	var doc jsonFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		offset := dec.InputOffset()
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		} else if key, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			// The decoder only notices unknown keys once it is done.
+			if i := bytes.Index(data, []byte(key)); i >= 0 {
+				offset = int64(i)
+			}
+		}
+		line := bytes.Count(data[:offset], []byte("\n")) + 1
+		return nil, 0, fmt.Errorf("%s: line %d: %v", path, line, err)
+	}
+	if doc.Schema < 1 || doc.Schema > schemaVersion {
+		return nil, 0, fmt.Errorf("%s: unsupported schema version %d", path, doc.Schema)
+	}
+
+	var problems []error
+	todos := []Todo{}
+	ids := map[int]bool{}
+	uids := map[string]int{}
+	for i, jt := range doc.Todos {
+		todo, errs := jt.todo()
+		todo.Position = i + 1
+		if todo.ID <= 0 || ids[todo.ID] {
+			errs = append(errs, fmt.Errorf("id: %d is missing, not positive or used twice", todo.ID))
+		}
+		ids[todo.ID] = true
+		if todo.UID != "" {
+			if first, ok := uids[todo.UID]; ok {
+				errs = append(errs, fmt.Errorf("uid: %q is already used by todos[%d]", todo.UID, first))
+			} else {
+				uids[todo.UID] = i
+			}
+		}
+		for _, err := range errs {
+			problems = append(problems, fmt.Errorf("todos[%d] (%q): %v", i, jt.Title, err))
+		}
+		todos = append(todos, todo)
+	}
+	for i, todo := range todos {
+		for _, id := range todo.BlockedBy {
+			if !ids[id] {
+				problems = append(problems, fmt.Errorf("todos[%d] (%q): blocked_by: there is no todo #%d", i, todo.Title, id))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, 0, fmt.Errorf("%s has %d problems:\n%w", path, len(problems), errors.Join(problems...))
+	}
+	return todos, doc.Schema, nil
+}
+
+func toJSONTodo(todo Todo) jsonTodo {
+	jt := jsonTodo{
+		ID:          todo.ID,
+		UID:         todo.UID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		Priority:    todo.Priority,
+		Recurrence:  todo.Recurrence,
+		Archived:    todo.Archived,
+		Tags:        todo.Tags,
+		Projects:    todo.Projects,
+		Contexts:    todo.Contexts,
+		BlockedBy:   todo.BlockedBy,
+		SubTodos:    toJSONSubTodos(todo.SubTodos),
+		TimeEntries: todo.TimeEntries,
+		Pomodoros:   todo.Pomodoros,
+		Activity:    todo.Activity,
+		Revisions:   todo.Revisions,
+	}
+	if !todo.CreatedAt.IsZero() {
+		jt.CreatedAt = &todo.CreatedAt
+	}
+	if !todo.CompletedAt.IsZero() {
+		jt.CompletedAt = &todo.CompletedAt
+	}
+	if !todo.Due.IsZero() {
+		jt.Due = formatDate(todo.Due)
+	}
+	if !todo.DeferUntil.IsZero() {
+		jt.DeferUntil = formatDate(todo.DeferUntil)
+	}
+	if todo.Estimate > 0 {
+		jt.Estimate = exactEstimate(todo.Estimate)
+	}
+	return jt
+}
+
+func toJSONSubTodos(subs []SubTodo) []jsonSubTodo {
+	var out []jsonSubTodo
+	for _, sub := range subs {
+		js := jsonSubTodo{
+			Title:     sub.Title,
+			Completed: sub.Completed,
+			Collapsed: sub.Collapsed,
+			Children:  toJSONSubTodos(sub.Children),
+		}
+		if sub.Estimate > 0 {
+			js.Estimate = exactEstimate(sub.Estimate)
+		}
+		out = append(out, js)
+	}
+	return out
+}
+
+func (jt jsonTodo) todo() (Todo, []error) {
+	var errs []error
+	todo := Todo{
+		ID:          jt.ID,
+		UID:         jt.UID,
+		Title:       jt.Title,
+		Description: jt.Description,
+		Completed:   jt.Completed,
+		Recurrence:  jt.Recurrence,
+		Archived:    jt.Archived,
+		Tags:        jt.Tags,
+		Projects:    jt.Projects,
+		Contexts:    jt.Contexts,
+		BlockedBy:   jt.BlockedBy,
+		TimeEntries: jt.TimeEntries,
+		Pomodoros:   jt.Pomodoros,
+		Activity:    jt.Activity,
+		Revisions:   jt.Revisions,
+	}
+	if jt.CreatedAt != nil {
+		todo.CreatedAt = *jt.CreatedAt
+	}
+	if jt.CompletedAt != nil {
+		todo.CompletedAt = *jt.CompletedAt
+	}
+
+	var err error
+	if jt.Priority != "" {
+		if todo.Priority, err = parsePriority(jt.Priority); err != nil {
+			errs = append(errs, fmt.Errorf("priority: %v", err))
+		}
+	}
+	if todo.Due, err = parseJSONDate(jt.Due); err != nil {
+		errs = append(errs, fmt.Errorf("due: %v", err))
+	}
+	if todo.DeferUntil, err = parseJSONDate(jt.DeferUntil); err != nil {
+		errs = append(errs, fmt.Errorf("defer_until: %v", err))
+	}
+	if todo.Estimate, err = parseJSONEstimate(jt.Estimate); err != nil {
+		errs = append(errs, fmt.Errorf("estimate: %v", err))
+	}
+	if todo.Recurrence != "" {
+		if _, err := parseRecurrence(todo.Recurrence); err != nil {
+			errs = append(errs, fmt.Errorf("recurrence: %v", err))
+		}
+	}
+	if todo.SubTodos, err = fromJSONSubTodos(jt.SubTodos); err != nil {
+		errs = append(errs, fmt.Errorf("sub_todos: %v", err))
+	}
+	return todo, errs
+}
+
+func fromJSONSubTodos(subs []jsonSubTodo) ([]SubTodo, error) {
+	var out []SubTodo
+	for i, js := range subs {
+		estimate, err := parseJSONEstimate(js.Estimate)
+		if err != nil {
+			return nil, fmt.Errorf("%q: estimate: %v", js.Title, err)
+		}
+		children, err := fromJSONSubTodos(js.Children)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, SubTodo{
+			ID:        i + 1,
+			Title:     js.Title,
+			Completed: js.Completed,
+			Collapsed: js.Collapsed,
+			Estimate:  estimate,
+			Children:  children,
+		})
+	}
+	return out, nil
+}
+
+func parseJSONDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(dateLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date like 2006-01-02", s)
+	}
+	return t, nil
+}
+
+func parseJSONEstimate(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a duration like 1h30m", s)
+	}
+	return d, nil
+}
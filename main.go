@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"strconv"
 
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -13,46 +12,77 @@ import (
 )
 
 func (m model) Init() tea.Cmd {
+	if m.ticking {
+		return tea.Batch(tea.EnterAltScreen, tick())
+	}
 	return tea.EnterAltScreen
 }
 
 func main() {
-	if len(os.Args) > 1 && (os.Args[1] == "--quick" || os.Args[1] == "-q") {
+	quick := len(os.Args) > 1 && (os.Args[1] == "--quick" || os.Args[1] == "-q")
+
+	var err error
+	cfg, err = loadConfig()
+	if err != nil {
+		if !quick {
+			fmt.Println("Error loading config:", err)
+			os.Exit(1)
+		}
+		// The quick view runs on every shell start, so a broken config
+		// shouldn't stop it.
+		fmt.Println("Warning: ignoring config:", err)
+		cfg = defaultConfig()
+	}
+	todoFile = getTodoFilePath()
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	if quick {
 		force := len(os.Args) > 2 && (os.Args[2] == "--force" || os.Args[2] == "-f")
 		showQuickView(force)
 		return
 	}
 
+	if cfg.Sync != "" {
+		if _, err := syncReplicas(true); err != nil {
+			fmt.Println("Error syncing todos:", err)
+			os.Exit(1)
+		}
+	}
+
+	// The table view can't show save errors, so find out now.
+	if cfg.Git {
+		if err := checkGitRepo(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+
 	todos, err := loadTodos()
 	if err != nil {
 		fmt.Println("Error loading todos:", err)
 		os.Exit(1)
 	}
+	ensureUIDs(todos)
 
 	columns := []table.Column{
 		{Title: "ID", Width: 4},
 		{Title: "Title", Width: 30},
 		{Title: "Description", Width: 60},
+		{Title: "Due", Width: 10},
 		{Title: "Done", Width: 6},
 	}
 
-	rows := []table.Row{}
-	for _, todo := range todos {
-		completed := "[ ]"
-		if todo.Completed {
-			completed = "[✓]"
-		}
-		rows = append(rows, table.Row{
-			strconv.Itoa(todo.ID),
-			todo.Title,
-			todo.Description,
-			completed,
-		})
-	}
-
 	t := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
 		table.WithFocused(true),
 		table.WithHeight(15),
 	)
@@ -79,14 +109,30 @@ func main() {
 	ta.SetWidth(50)
 	ta.SetHeight(5)
 
+	si := textinput.New()
+	si.Placeholder = "Sub-todo title"
+	si.CharLimit = 100
+	si.Width = 50
+
+	pi := textinput.New()
+	pi.CharLimit = 100
+	pi.Width = 50
+
 	m := model{
 		table:          t,
 		todos:          todos,
 		mode:           tableView,
 		titleInput:     ti,
 		descInput:      ta,
+		subInput:       si,
+		promptInput:    pi,
 		selectedSubIdx: 0,
+		expanded:       map[string]bool{},
+		marked:         map[string]bool{},
+		lastSaved:      cloneTodos(todos),
 	}
+	m.updateTable()
+	m.ticking = m.needsTick()
 
 	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
 		fmt.Println("Error running program:", err)
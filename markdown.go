@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readMarkdown turns a markdown file into todos. Every "##" (or deeper)
+// heading starts a todo; the checklist below it becomes its sub-todos and
+// the other text its description. A "# Title" line names any checklist
+// that appears before the first such heading.
+func readMarkdown(r io.Reader) ([]Todo, error) {
+	var todos []Todo
+	var title string
+	var body []string
+	preamble := "Imported checklist"
+
+	flush := func() {
+		desc, subTodos := parseChecklist(strings.Join(body, "\n"))
+		body = nil
+		if title == "" {
+			if len(subTodos) == 0 {
+				return
+			}
+			title = preamble
+		}
+
+		todo := Todo{Description: desc, SubTodos: subTodos}
+		lower := strings.ToLower(title)
+		if strings.HasPrefix(lower, "[ ] ") || strings.HasPrefix(lower, "[x] ") {
+			todo.Completed = lower[1] == 'x'
+			title = strings.TrimSpace(title[4:])
+		}
+		todo.Title, todo.Estimate = splitEstimate(title)
+		todos = append(todos, todo)
+	}
+
+	scanner := bufio.NewScanner(r)
+	inFence := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+
+		level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		isHeading := !inFence && level > 0 && strings.HasPrefix(trimmed[level:], " ")
+		switch {
+		case isHeading && level == 1:
+			flush()
+			title = ""
+			preamble = strings.TrimSpace(trimmed[1:])
+		case isHeading:
+			flush()
+			title = strings.TrimSpace(trimmed[level:])
+		default:
+			body = append(body, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return todos, nil
+}
+
+// writeMarkdown writes the list in the layout readMarkdown reads.
+func writeMarkdown(w io.Writer, todos []Todo) error {
+	var b strings.Builder
+	b.WriteString("# Todos\n")
+	for _, todo := range todos {
+		box := ""
+		if todo.Completed {
+			box = "[x] "
+		}
+		fmt.Fprintf(&b, "\n## %s%s\n", box, withEstimate(todo.Title, todo.Estimate))
+		if todo.Description != "" {
+			b.WriteString("\n" + todo.Description + "\n")
+		}
+		if len(todo.SubTodos) > 0 {
+			b.WriteString("\n" + formatChecklist(todo.SubTodos, 0))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// parseChecklist is parseSubTodosFromDescription for imported markdown: it
+// also takes "* " and "+ " bullets, and a "[ ]" or "[x]" box after the
+// bullet sets whether the item is checked.
+func parseChecklist(text string) (string, []SubTodo) {
+	return splitListItems(text, true, func(trimmed string) (string, bool, bool) {
+		item, ok := listItem(trimmed)
+		completed := false
+		if box := strings.ToLower(item); strings.HasPrefix(box, "[ ]") || strings.HasPrefix(box, "[x]") {
+			completed = box[1] == 'x'
+			item = strings.TrimSpace(item[3:])
+		}
+		return item, completed, ok
+	})
+}
+
+// listItem returns the text after a "- ", "* " or "+ " bullet.
+func listItem(line string) (string, bool) {
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, bullet) {
+			return strings.TrimSpace(line[len(bullet):]), true
+		}
+	}
+	return "", false
+}
+
+// formatChecklist writes sub-todos as the nested "- [ ]" and "- [x]" items
+// parseChecklist reads.
+func formatChecklist(subTodos []SubTodo, depth int) string {
+	var b strings.Builder
+	for _, sub := range subTodos {
+		box := "[ ] "
+		if sub.Completed {
+			box = "[x] "
+		}
+		b.WriteString(strings.Repeat("  ", depth) + "- " + box + withEstimate(sub.Title, sub.Estimate) + "\n")
+		b.WriteString(formatChecklist(sub.Children, depth+1))
+	}
+	return b.String()
+}
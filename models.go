@@ -12,16 +12,35 @@ type SubTodo struct {
 	ID        int
 	Title     string
 	Completed bool
+	Collapsed bool          `json:",omitempty"`
+	Estimate  time.Duration `json:",omitempty"`
+	Children  []SubTodo     `json:",omitempty"`
 }
 
 type Todo struct {
 	ID          int
+	UID         string
 	Title       string
 	Description string
 	Completed   bool
 	CreatedAt   time.Time
 	CompletedAt time.Time
 	SubTodos    []SubTodo
+	Position    int
+	Tags        []string
+	Projects    []string
+	Contexts    []string
+	Priority    string
+	Due         time.Time
+	Archived    bool
+	Recurrence  string
+	DeferUntil  time.Time
+	BlockedBy   []int
+	TimeEntries []TimeEntry
+	Estimate    time.Duration
+	Pomodoros   []time.Time
+	Activity    []Activity
+	Revisions   []Revision
 }
 
 type viewMode int
@@ -31,6 +50,12 @@ const (
 	detailView
 	addView
 	editView
+	pickParentView
+	confirmView
+	promptView
+	snoozeView
+	focusView
+	historyView
 )
 
 type filterMode int
@@ -39,8 +64,27 @@ const (
 	showAll filterMode = iota
 	showActive
 	showCompleted
+	showDeferred
+	showArchived
+
+	filterCount = showArchived + 1
 )
 
+type subEditMode int
+
+const (
+	subEditNone subEditMode = iota
+	subEditAdd
+	subEditRename
+)
+
+// tableRow is what one table row shows: a todo, or one of its sub-todos
+// when path is set.
+type tableRow struct {
+	id   int
+	path []int
+}
+
 type model struct {
 	table          table.Model
 	todos          []Todo
@@ -50,6 +94,24 @@ type model struct {
 	descInput      textarea.Model
 	editingID      int
 	selectedSubIdx int
+	subInput       textinput.Model
+	subEdit        subEditMode
+	rows           []tableRow
+	expanded       map[string]bool
+	pickSourceID   int
+	pickIdx        int
+	confirm        confirmation
+	prompt         inputPrompt
+	promptInput    textinput.Model
+	marked         map[string]bool
+	markAnchor     string
+	ticking        bool
+	undoStack      [][]Todo
+	lastSaved      []Todo
+	snoozeIDs      []int
+	focus          focusSession
+	historyIdx     int
+	historyBase    int
 	width          int
 	height         int
 }
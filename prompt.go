@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// inputPrompt asks for a single line of text in a modal. If onSubmit
+// returns an error the prompt stays open and shows it.
+type inputPrompt struct {
+	title      string
+	hint       string
+	onSubmit   func(m *model, value string) error
+	returnMode viewMode
+	err        string
+}
+
+func (m *model) askInput(title, hint, value string, onSubmit func(m *model, value string) error) {
+	m.prompt = inputPrompt{
+		title:      title,
+		hint:       hint,
+		onSubmit:   onSubmit,
+		returnMode: m.mode,
+	}
+	m.promptInput.SetValue(value)
+	m.promptInput.CursorEnd()
+	m.promptInput.Focus()
+	m.mode = promptView
+}
+
+func (m model) handlePromptKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc":
+		m.mode = m.prompt.returnMode
+		m.promptInput.Blur()
+		return m, nil
+	case "enter":
+		p := m.prompt
+		m.mode = p.returnMode
+		if err := p.onSubmit(&m, strings.TrimSpace(m.promptInput.Value())); err != nil {
+			p.err = err.Error()
+			m.prompt = p
+			m.mode = promptView
+			return m, nil
+		}
+		m.promptInput.Blur()
+		return m, nil
+	}
+
+	m.promptInput, cmd = m.promptInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) renderPromptView() string {
+	popupWidth := m.width - 20
+	if popupWidth > 60 {
+		popupWidth = 60
+	}
+	if popupWidth < 40 {
+		popupWidth = 40
+	}
+	m.promptInput.Width = popupWidth - 10
+
+	content := titleStyle.Render(m.prompt.title) + "\n\n"
+	content += m.promptInput.View() + "\n"
+	if m.prompt.hint != "" {
+		content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(m.prompt.hint) + "\n"
+	}
+	if m.prompt.err != "" {
+		content += lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(m.prompt.err) + "\n"
+	}
+	content += "\n"
+	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("[enter] ok  [esc] cancel")
+
+	popup := popupStyle.Width(popupWidth).Render(content)
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		popup,
+	)
+}
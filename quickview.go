@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -66,7 +68,7 @@ func showQuickView(force bool) {
 
 	activeTodos := []Todo{}
 	for _, todo := range todos {
-		if !todo.Completed {
+		if !todo.Completed && !todo.Archived && !isDeferred(todo, time.Now()) {
 			activeTodos = append(activeTodos, todo)
 		}
 	}
@@ -111,19 +113,16 @@ func showQuickView(force bool) {
 		}
 
 		if len(todo.SubTodos) > 0 {
-			completed := 0
-			for _, sub := range todo.SubTodos {
-				checkbox := "[ ]"
-				if sub.Completed {
-					checkbox = "[✓]"
-					completed++
-				}
-				fmt.Printf("   %s %s\n",
-					subTodoStyle.Render(checkbox),
+			for _, ref := range visibleSubTodos(todo.SubTodos) {
+				sub := subTodoAt(&todo.SubTodos, ref.path)
+				fmt.Printf("   %s%s %s\n",
+					strings.Repeat("  ", ref.depth),
+					subTodoStyle.Render(subTodoCheckbox(*sub)),
 					subTodoStyle.Render(sub.Title))
 			}
 
-			progressText := fmt.Sprintf("(%d/%d done)", completed, len(todo.SubTodos))
+			completed, total := subTodoProgress(todo.SubTodos)
+			progressText := fmt.Sprintf("(%d/%d done)", completed, total)
 			fmt.Printf("   %s\n", progressStyle.Render(progressText))
 		}
 
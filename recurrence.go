@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type frequency int
+
+const (
+	daily frequency = iota
+	weekly
+	monthly
+)
+
+// recurrence is a parsed repeat rule. It is stored on the todo as the text
+// the user typed and parsed again when needed.
+type recurrence struct {
+	freq     frequency
+	interval int
+	weekdays []time.Weekday
+	monthDay int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// parseRecurrence understands "daily", "weekly", "monthly", "weekdays",
+// "every N days", "every N weeks", "every N months", "monthly on 15" and
+// the FREQ, INTERVAL, BYDAY and BYMONTHDAY parts of an RFC 5545 RRULE.
+func parseRecurrence(rule string) (recurrence, error) {
+	s := strings.ToLower(strings.TrimSpace(rule))
+	r := recurrence{interval: 1}
+
+	switch {
+	case s == "daily":
+		r.freq = daily
+	case s == "weekly":
+		r.freq = weekly
+	case s == "monthly":
+		r.freq = monthly
+	case s == "weekdays":
+		r.freq = weekly
+		r.weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	case strings.HasPrefix(s, "every "):
+		fields := strings.Fields(s)
+		if len(fields) != 3 {
+			return r, fmt.Errorf("expected \"every N days|weeks|months\"")
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return r, fmt.Errorf("invalid interval %q", fields[1])
+		}
+		r.interval = n
+		switch strings.TrimSuffix(fields[2], "s") {
+		case "day":
+			r.freq = daily
+		case "week":
+			r.freq = weekly
+		case "month":
+			r.freq = monthly
+		default:
+			return r, fmt.Errorf("unknown unit %q", fields[2])
+		}
+	case strings.HasPrefix(s, "monthly on "):
+		day, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(s, "monthly on "), "day "))
+		if err != nil || day < 1 || day > 31 {
+			return r, fmt.Errorf("invalid day of month")
+		}
+		r.freq = monthly
+		r.monthDay = day
+	case strings.HasPrefix(s, "rrule:") || strings.HasPrefix(s, "freq="):
+		return parseRRule(strings.TrimPrefix(s, "rrule:"))
+	default:
+		return r, fmt.Errorf("unrecognised repeat rule %q", rule)
+	}
+	return r, nil
+}
+
+func parseRRule(s string) (recurrence, error) {
+	r := recurrence{interval: 1}
+	hasFreq := false
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(strings.ToUpper(part), "=")
+		if !ok {
+			return r, fmt.Errorf("malformed RRULE part %q", part)
+		}
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY":
+				r.freq = daily
+			case "WEEKLY":
+				r.freq = weekly
+			case "MONTHLY":
+				r.freq = monthly
+			default:
+				return r, fmt.Errorf("unsupported FREQ %q", value)
+			}
+			hasFreq = true
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return r, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			r.interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				wd, ok := weekdayCodes[code]
+				if !ok {
+					return r, fmt.Errorf("unsupported BYDAY %q", code)
+				}
+				r.weekdays = append(r.weekdays, wd)
+			}
+		case "BYMONTHDAY":
+			day, err := strconv.Atoi(value)
+			if err != nil || day < 1 || day > 31 {
+				return r, fmt.Errorf("invalid BYMONTHDAY %q", value)
+			}
+			r.monthDay = day
+		default:
+			return r, fmt.Errorf("unsupported RRULE part %q", key)
+		}
+	}
+
+	if !hasFreq {
+		return r, fmt.Errorf("RRULE needs a FREQ")
+	}
+	return r, nil
+}
+
+// next returns the first occurrence strictly after from.
+func (r recurrence) next(from time.Time) time.Time {
+	switch r.freq {
+	case weekly:
+		if len(r.weekdays) == 0 {
+			return from.AddDate(0, 0, 7*r.interval)
+		}
+		day := from
+		for i := 0; i < 14; i++ {
+			day = day.AddDate(0, 0, 1)
+			if day.Weekday() == time.Monday && r.interval > 1 {
+				day = day.AddDate(0, 0, 7*(r.interval-1))
+			}
+			for _, wd := range r.weekdays {
+				if day.Weekday() == wd {
+					return day
+				}
+			}
+		}
+		return day
+	case monthly:
+		month := time.Date(from.Year(), from.Month(), 1, from.Hour(), from.Minute(), 0, 0, from.Location())
+		if r.monthDay == 0 {
+			// AddDate would carry Jan 31 over into March.
+			month = month.AddDate(0, r.interval, 0)
+			return month.AddDate(0, 0, clampMonthDay(month, from.Day())-1)
+		}
+		if from.Day() >= clampMonthDay(month, r.monthDay) {
+			month = month.AddDate(0, r.interval, 0)
+		}
+		return month.AddDate(0, 0, clampMonthDay(month, r.monthDay)-1)
+	default:
+		return from.AddDate(0, 0, r.interval)
+	}
+}
+
+// clampMonthDay keeps "monthly on 31" landing on the last day of shorter
+// months.
+func clampMonthDay(month time.Time, day int) int {
+	last := time.Date(month.Year(), month.Month()+1, 0, 0, 0, 0, 0, month.Location()).Day()
+	if day > last {
+		return last
+	}
+	return day
+}
+
+// spawnRecurrences creates the next occurrence of every completed todo that
+// still carries a repeat rule. The completed todo keeps its record but
+// hands the rule over to the new one, so reopening and completing it again
+// does not spawn a duplicate.
+func (m *model) spawnRecurrences() {
+	for i := 0; i < len(m.todos); i++ {
+		todo := m.todos[i]
+		if !todo.Completed || todo.Recurrence == "" {
+			continue
+		}
+		rule, err := parseRecurrence(todo.Recurrence)
+		if err != nil {
+			continue
+		}
+
+		base := todo.Due
+		if base.IsZero() {
+			c := todo.CompletedAt
+			base = time.Date(c.Year(), c.Month(), c.Day(), 0, 0, 0, 0, c.Location())
+		}
+
+		next := Todo{
+			ID:          m.nextTodoID(),
+			UID:         newUID(),
+			Title:       todo.Title,
+			Description: todo.Description,
+			CreatedAt:   time.Now(),
+			SubTodos:    cloneSubTodos(todo.SubTodos),
+			Tags:        append([]string(nil), todo.Tags...),
+			Priority:    todo.Priority,
+			Estimate:    todo.Estimate,
+			Due:         rule.next(base),
+			Recurrence:  todo.Recurrence,
+		}
+		setSubTodosCompleted(next.SubTodos, false)
+		logActivity(&next, activityCreated, fmt.Sprintf("repeats #%d", todo.ID))
+		recordRevision(&next)
+
+		m.todos[i].Recurrence = ""
+		m.todos = append(m.todos, Todo{})
+		copy(m.todos[i+2:], m.todos[i+1:])
+		m.todos[i+1] = next
+		i++
+	}
+	normalizePositions(m.todos)
+}
+
+func (m *model) setRecurrence(ids []int, value string) error {
+	if value != "" {
+		if _, err := parseRecurrence(value); err != nil {
+			return err
+		}
+	}
+	m.bulkUpdate(ids, func(todo *Todo) {
+		todo.Recurrence = value
+	})
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func isDeferred(todo Todo, now time.Time) bool {
+	return !todo.DeferUntil.IsZero() && now.Before(todo.DeferUntil)
+}
+
+func (m *model) startSnooze(ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	m.snoozeIDs = ids
+	m.mode = snoozeView
+}
+
+func (m *model) snooze(ids []int, until time.Time) {
+	m.bulkUpdate(ids, func(todo *Todo) {
+		todo.DeferUntil = until
+	})
+}
+
+func (m model) handleSnoozeKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	ids := m.snoozeIDs
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tableView
+	case "t":
+		m.mode = tableView
+		m.snooze(ids, today.AddDate(0, 0, 1))
+	case "w":
+		m.mode = tableView
+		days := (int(time.Monday) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		m.snooze(ids, today.AddDate(0, 0, days))
+	case "p":
+		m.mode = tableView
+		m.askInput("Defer until", "2006-01-02, fri, +3d, +2w", "",
+			func(m *model, value string) error {
+				until, err := parseDate(value, time.Now())
+				if err != nil {
+					return err
+				}
+				m.snooze(ids, until)
+				return nil
+			})
+	case "c":
+		m.mode = tableView
+		m.snooze(ids, time.Time{})
+	}
+	return m, nil
+}
+
+func (m model) renderSnoozeView() string {
+	popupWidth := m.width - 20
+	if popupWidth > 60 {
+		popupWidth = 60
+	}
+	if popupWidth < 40 {
+		popupWidth = 40
+	}
+
+	title := "Snooze"
+	if len(m.snoozeIDs) > 1 {
+		title = fmt.Sprintf("Snooze %d todos", len(m.snoozeIDs))
+	} else if i := m.todoIndex(m.snoozeIDs[0]); i >= 0 {
+		title = fmt.Sprintf("Snooze \"%s\"", m.todos[i].Title)
+	}
+
+	content := titleStyle.Render(title) + "\n\n"
+	content += "  [t] until tomorrow\n"
+	content += "  [w] until next week\n"
+	content += "  [p] pick a date...\n"
+	content += "  [c] clear snooze\n\n"
+	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("[esc] cancel")
+
+	popup := popupStyle.Width(popupWidth).Render(content)
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		popup,
+	)
+}
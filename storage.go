@@ -3,13 +3,30 @@ package main
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 func getTodoFilePath() string {
+	if cfg.Sync != "" {
+		return filepath.Join(expandHome(cfg.Sync), deviceName()+replicaSuffix)
+	}
+	return localTodoFilePath()
+}
+
+// localTodoFilePath is where todos are kept when sync is off.
+func localTodoFilePath() string {
+	if cfg.File != "" {
+		return expandHome(cfg.File)
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "todos.csv"
@@ -17,74 +34,492 @@ func getTodoFilePath() string {
 	return filepath.Join(home, "Documents", "todos.csv")
 }
 
-var csvFile = getTodoFilePath()
+func expandHome(path string) string {
+	home, err := os.UserHomeDir()
+	if rest, ok := strings.CutPrefix(path, "~/"); ok && err == nil {
+		return filepath.Join(home, rest)
+	}
+	return path
+}
+
+// todoFile is set again once the config is loaded.
+var todoFile = getTodoFilePath()
+
+// store reads and writes the whole list in one file format. load returns
+// the todos in file order along with the file's schema version.
+type store interface {
+	load(path string) ([]Todo, int, error)
+	save(path string, todos []Todo) error
+}
+
+// stores maps a file extension to its format; anything else is CSV.
+var stores = map[string]store{
+	".csv":     csvStore{},
+	".json":    jsonStore{},
+	".journal": journalStore{},
+}
+
+func storeFor(path string) store {
+	if s, ok := stores[strings.ToLower(filepath.Ext(path))]; ok {
+		return s
+	}
+	return csvStore{}
+}
 
 func loadTodos() ([]Todo, error) {
-	file, err := os.Open(csvFile)
+	todos, version, err := storeFor(todoFile).load(todoFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return []Todo{}, nil
 		}
 		return nil, err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	// Files written before positions existed have no Position column;
+	// the stable sort keeps their row order.
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todos[i].Position < todos[j].Position
+	})
+	normalizePositions(todos)
+
+	if version < schemaVersion {
+		if err := migrateTodos(todos, version); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Git {
+		rememberCommitted(todos)
+	}
+	return todos, nil
+}
+
+func saveTodos(todos []Todo) error {
+	if cfg.Git {
+		return saveAndCommit(todos, "")
+	}
+	return storeFor(todoFile).save(todoFile, todos)
+}
+
+// migrateTodos upgrades todos read from an older file and saves them right
+// away, keeping a copy of the old file next to it with the version in its
+// name. The upgraded list is written to a temporary file that is then
+// renamed over the old one, so a failed save leaves the old file as it was.
+func migrateTodos(todos []Todo, version int) error {
+	if cfg.Git {
+		if err := checkGitRepo(); err != nil {
+			return err
+		}
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", todoFile, version)
+	if err := copyFile(todoFile, backup); err != nil {
+		return err
+	}
+	for v := version; v < schemaVersion; v++ {
+		migrations[v](todos)
+	}
+
+	ext := filepath.Ext(todoFile)
+	tmp := strings.TrimSuffix(todoFile, ext) + ".migrating" + ext
+	os.Remove(tmp)
+	if err := storeFor(tmp).save(tmp, todos); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, todoFile); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if st, ok := journals[tmp]; ok {
+		journals[todoFile] = st
+		delete(journals, tmp)
+	}
+
+	if cfg.Git {
+		return saveAndCommit(todos, fmt.Sprintf("migrate the list to schema version %d", schemaVersion))
+	}
+	return nil
+}
+
+// migrateCommand copies the list into a file of another format and points
+// the config at it. The old file is left where it was.
+func migrateCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	to := fs.String("to", "", "format to move to: csv, json or journal")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	want, ok := stores["."+*to]
+	if !ok || fs.NArg() > 1 {
+		return fmt.Errorf("usage: todo migrate --to csv|json|journal [FILE]")
+	}
+
+	target := fs.Arg(0)
+	if target == "" {
+		target = strings.TrimSuffix(todoFile, filepath.Ext(todoFile)) + "." + *to
+	}
+	if storeFor(target) != want {
+		return fmt.Errorf("%s: the file name must end in .%s", target, *to)
+	}
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	}
+
+	todos, err := loadTodos()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := storeFor(target).save(target, todos); err != nil {
+		return err
+	}
+
+	cfg.File = target
+	if err := saveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d todos to %s and set \"file\" in %s.\nThe old file is still at %s.\n",
+		len(todos), target, getConfigFilePath(), todoFile)
+	return nil
+}
+
+type csvStore struct{}
+
+// schemaVersion is written as "#schema:N" on the first line of the file.
+// Files without that line predate versioning and are version 1.
+const schemaVersion = 2
+
+// migrations[v] upgrades todos read from a version v file to version v+1.
+var migrations = map[int]func(todos []Todo){
+	// Version 2 identifies todos by UID across exports and devices.
+	1: func(todos []Todo) { ensureUIDs(todos) },
+}
+
+// fieldProblem is something wrong with one row of the file. An empty
+// field means the row as a whole couldn't be read.
+type fieldProblem struct {
+	line  int
+	field string
+	value string
+	err   error
+}
+
+func (p fieldProblem) Error() string {
+	if p.field == "" {
+		return fmt.Sprintf("line %d: %v", p.line, p.err)
+	}
+	return fmt.Sprintf("line %d: %s: %v", p.line, p.field, p.err)
+}
+
+// loadError lists every problem found in the file. Loading stops rather
+// than guessing, so the next save can't overwrite what's left of a
+// damaged row.
+type loadError struct {
+	path     string
+	problems []fieldProblem
+}
+
+func (e *loadError) Error() string {
+	s := fmt.Sprintf("%s has %d problems (run \"todo doctor\" to fix them):", e.path, len(e.problems))
+	for _, p := range e.problems {
+		s += "\n  " + p.Error()
+	}
+	return s
+}
+
+// todoRow is one record of the file as read, with the todo parsed from it
+// and whatever was wrong along the way. Fields that failed to parse are
+// left at their zero value; a broken row couldn't be split into fields.
+type todoRow struct {
+	line     int
+	raw      string
+	todo     Todo
+	problems []fieldProblem
+	broken   bool
+}
+
+type csvContents struct {
+	version int
+	header  string
+	rows    []todoRow
+}
+
+func readTodoFile(path string) (csvContents, error) {
+	var f csvContents
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	content := string(data)
+
+	f.version = 1
+	if first, _, _ := strings.Cut(content, "\n"); strings.HasPrefix(first, "#schema:") {
+		f.version, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(first, "#schema:")))
+		if err != nil || f.version < 1 {
+			return f, fmt.Errorf("%s: line 1: invalid schema version %q", path, strings.TrimPrefix(first, "#schema:"))
+		}
+	}
+	if f.version > schemaVersion {
+		return f, fmt.Errorf("%s uses schema version %d, but this version of todo only knows up to %d", path, f.version, schemaVersion)
 	}
 
-	var todos []Todo
-	for i, record := range records {
-		if i == 0 {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	var columns map[string]int
+	var width int
+	for {
+		start := reader.InputOffset()
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		raw := content[start:reader.InputOffset()]
+		for strings.HasPrefix(raw, "#") {
+			_, raw, _ = strings.Cut(raw, "\n")
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			f.rows = append(f.rows, todoRow{
+				line:     parseErr.StartLine,
+				raw:      raw,
+				problems: []fieldProblem{{line: parseErr.StartLine, err: parseErr.Err}},
+				broken:   true,
+			})
 			continue
 		}
-		if len(record) < 4 {
+		if err != nil {
+			return f, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if columns == nil {
+			columns = map[string]int{}
+			for i, name := range record {
+				columns[name] = i
+			}
+			if _, ok := columns["ID"]; !ok {
+				return f, fmt.Errorf("%s: line %d: the header has no ID column", path, line)
+			}
+			f.header = raw
+			width = len(record)
 			continue
 		}
 
-		id, _ := strconv.Atoi(record[0])
-		completed := record[3] == "true"
+		row := todoRow{line: line, raw: raw}
+		if len(record) != width {
+			row.problems = append(row.problems, fieldProblem{line: line,
+				err: fmt.Errorf("row has %d columns, the header has %d", len(record), width)})
+		}
+		p := rowParser{line: line, record: record, columns: columns}
+		row.todo = p.todo()
+		row.problems = append(row.problems, p.problems...)
+		f.rows = append(f.rows, row)
+	}
+	checkRows(f.rows)
+	return f, nil
+}
 
-		var createdAt, completedAt time.Time
-		if len(record) > 4 && record[4] != "" {
-			createdAt, _ = time.Parse(time.RFC3339, record[4])
+// checkRows finds problems between rows: IDs used twice, UIDs used twice
+// and blockers that don't exist.
+func checkRows(rows []todoRow) {
+	ids := map[int]int{}
+	uids := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		if id := row.todo.ID; id > 0 {
+			if line, ok := ids[id]; ok {
+				row.problems = append(row.problems, fieldProblem{row.line, "ID", strconv.Itoa(id),
+					fmt.Errorf("#%d is already used on line %d", id, line)})
+			} else {
+				ids[id] = row.line
+			}
 		}
-		if len(record) > 5 && record[5] != "" {
-			completedAt, _ = time.Parse(time.RFC3339, record[5])
+		if uid := row.todo.UID; uid != "" {
+			if line, ok := uids[uid]; ok {
+				row.problems = append(row.problems, fieldProblem{row.line, "UID", uid,
+					fmt.Errorf("already used on line %d", line)})
+			} else {
+				uids[uid] = row.line
+			}
+		}
+	}
+	for i := range rows {
+		row := &rows[i]
+		for _, id := range row.todo.BlockedBy {
+			if _, ok := ids[id]; !ok {
+				row.problems = append(row.problems, fieldProblem{row.line, "BlockedBy", strconv.Itoa(id),
+					fmt.Errorf("there is no todo #%d", id)})
+			}
 		}
+	}
+}
+
+// rowParser reads the fields of one record by column name, collecting a
+// problem for each value that doesn't parse.
+type rowParser struct {
+	line     int
+	record   []string
+	columns  map[string]int
+	problems []fieldProblem
+}
 
-		var subTodos []SubTodo
-		if len(record) > 6 && record[6] != "" {
-			json.Unmarshal([]byte(record[6]), &subTodos)
+func (p *rowParser) get(name string) string {
+	i, ok := p.columns[name]
+	if !ok || i >= len(p.record) {
+		return ""
+	}
+	return p.record[i]
+}
+
+func (p *rowParser) fail(name string, err error) {
+	p.problems = append(p.problems, fieldProblem{p.line, name, p.get(name), err})
+}
+
+func (p *rowParser) int(name string) int {
+	v := p.get(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		p.fail(name, fmt.Errorf("%q is not a number", v))
+		return 0
+	}
+	return n
+}
+
+func (p *rowParser) bool(name string) bool {
+	v := p.get(name)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.fail(name, fmt.Errorf("%q is not true or false", v))
+	}
+	return b
+}
+
+func (p *rowParser) time(name string) time.Time {
+	v := p.get(name)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		p.fail(name, fmt.Errorf("%q is not an RFC 3339 time", v))
+		return time.Time{}
+	}
+	return t
+}
+
+func (p *rowParser) list(name string) []string {
+	if v := p.get(name); v != "" {
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+func (p *rowParser) json(name string, dst interface{}) {
+	if v := p.get(name); v != "" {
+		if err := json.Unmarshal([]byte(v), dst); err != nil {
+			p.fail(name, fmt.Errorf("invalid JSON: %v", err))
 		}
+	}
+}
 
-		todos = append(todos, Todo{
-			ID:          id,
-			Title:       record[1],
-			Description: record[2],
-			Completed:   completed,
-			CreatedAt:   createdAt,
-			CompletedAt: completedAt,
-			SubTodos:    subTodos,
-		})
+func (p *rowParser) todo() Todo {
+	todo := Todo{
+		ID:          p.int("ID"),
+		UID:         p.get("UID"),
+		Title:       p.get("Title"),
+		Description: p.get("Description"),
+		Completed:   p.bool("Completed"),
+		CreatedAt:   p.time("CreatedAt"),
+		CompletedAt: p.time("CompletedAt"),
+		Position:    p.int("Position"),
+		Tags:        p.list("Tags"),
+		Projects:    p.list("Projects"),
+		Contexts:    p.list("Contexts"),
+		Due:         p.time("Due"),
+		Archived:    p.bool("Archived"),
+		Recurrence:  p.get("Recurrence"),
+		DeferUntil:  p.time("DeferUntil"),
+	}
+	if v := p.get("ID"); v == "" {
+		p.fail("ID", fmt.Errorf("missing"))
+	} else if n, err := strconv.Atoi(v); err == nil && n <= 0 {
+		p.fail("ID", fmt.Errorf("must be positive"))
 	}
 
-	return todos, nil
+	if v := p.get("Priority"); v != "" {
+		var err error
+		if todo.Priority, err = parsePriority(v); err != nil {
+			p.fail("Priority", err)
+		}
+	}
+	if v := p.get("BlockedBy"); v != "" {
+		var err error
+		if todo.BlockedBy, err = parseBlockers(v); err != nil {
+			p.fail("BlockedBy", err)
+		}
+	}
+	if v := p.get("Estimate"); v != "" {
+		var err error
+		if todo.Estimate, err = time.ParseDuration(v); err != nil || todo.Estimate < 0 {
+			todo.Estimate = 0
+			p.fail("Estimate", fmt.Errorf("%q is not a duration", v))
+		}
+	}
+	if todo.Recurrence != "" {
+		if _, err := parseRecurrence(todo.Recurrence); err != nil {
+			todo.Recurrence = ""
+			p.fail("Recurrence", err)
+		}
+	}
+
+	p.json("SubTodos", &todo.SubTodos)
+	p.json("TimeEntries", &todo.TimeEntries)
+	p.json("Pomodoros", &todo.Pomodoros)
+	p.json("Activity", &todo.Activity)
+	p.json("Revisions", &todo.Revisions)
+	return todo
 }
 
-func saveTodos(todos []Todo) error {
-	file, err := os.Create(csvFile)
+func (csvStore) load(path string) ([]Todo, int, error) {
+	f, err := readTodoFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var problems []fieldProblem
+	todos := []Todo{}
+	for _, row := range f.rows {
+		problems = append(problems, row.problems...)
+		todos = append(todos, row.todo)
+	}
+	if len(problems) > 0 {
+		return nil, 0, &loadError{path, problems}
+	}
+	return todos, f.version, nil
+}
+
+func (csvStore) save(path string, todos []Todo) error {
+	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
+	// Closing twice is harmless; the error that counts is returned below.
 	defer file.Close()
 
+	if _, err := fmt.Fprintf(file, "#schema:%d\n", schemaVersion); err != nil {
+		return err
+	}
+
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
 
-	writer.Write([]string{"ID", "Title", "Description", "Completed", "CreatedAt", "CompletedAt", "SubTodos"})
+	writer.Write([]string{"ID", "Title", "Description", "Completed", "CreatedAt", "CompletedAt", "SubTodos", "Position", "Tags", "Priority", "Due", "Archived", "Recurrence", "DeferUntil", "BlockedBy", "TimeEntries", "Estimate", "Pomodoros", "Activity", "Revisions", "Projects", "Contexts", "UID"})
 
 	for _, todo := range todos {
 		createdAt := ""
@@ -96,6 +531,50 @@ func saveTodos(todos []Todo) error {
 			completedAt = todo.CompletedAt.Format(time.RFC3339)
 		}
 
+		due := ""
+		if !todo.Due.IsZero() {
+			due = todo.Due.Format(time.RFC3339)
+		}
+
+		deferUntil := ""
+		if !todo.DeferUntil.IsZero() {
+			deferUntil = todo.DeferUntil.Format(time.RFC3339)
+		}
+
+		blockedBy := make([]string, len(todo.BlockedBy))
+		for i, id := range todo.BlockedBy {
+			blockedBy[i] = strconv.Itoa(id)
+		}
+
+		estimate := ""
+		if todo.Estimate > 0 {
+			estimate = exactEstimate(todo.Estimate)
+		}
+
+		revisionsJSON := ""
+		if len(todo.Revisions) > 0 {
+			data, _ := json.Marshal(todo.Revisions)
+			revisionsJSON = string(data)
+		}
+
+		activityJSON := ""
+		if len(todo.Activity) > 0 {
+			data, _ := json.Marshal(todo.Activity)
+			activityJSON = string(data)
+		}
+
+		pomodorosJSON := ""
+		if len(todo.Pomodoros) > 0 {
+			data, _ := json.Marshal(todo.Pomodoros)
+			pomodorosJSON = string(data)
+		}
+
+		timeEntriesJSON := ""
+		if len(todo.TimeEntries) > 0 {
+			data, _ := json.Marshal(todo.TimeEntries)
+			timeEntriesJSON = string(data)
+		}
+
 		subTodosJSON := ""
 		if len(todo.SubTodos) > 0 {
 			data, _ := json.Marshal(todo.SubTodos)
@@ -110,11 +589,38 @@ func saveTodos(todos []Todo) error {
 			createdAt,
 			completedAt,
 			subTodosJSON,
+			strconv.Itoa(todo.Position),
+			strings.Join(todo.Tags, ","),
+			todo.Priority,
+			due,
+			strconv.FormatBool(todo.Archived),
+			todo.Recurrence,
+			deferUntil,
+			strings.Join(blockedBy, ","),
+			timeEntriesJSON,
+			estimate,
+			pomodorosJSON,
+			activityJSON,
+			revisionsJSON,
+			strings.Join(todo.Projects, ","),
+			strings.Join(todo.Contexts, ","),
+			todo.UID,
 		}
 		if err := writer.Write(record); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// normalizePositions rewrites positions as 1..n following slice order.
+func normalizePositions(todos []Todo) {
+	for i := range todos {
+		todos[i].Position = i + 1
+	}
 }
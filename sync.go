@@ -0,0 +1,625 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Sync mode shares todos between devices through a folder that a file
+// sync tool keeps in step. Each device writes only its own replica,
+// <device>.todo.json, so the tool never has two writers for one file. To
+// take in another device's changes, its replica is merged three ways with
+// the copy of it this device kept when it last merged it, in
+// .base/<device>/. Only this device's own copies are used: the other
+// device's copy of our replica can arrive before its replica does.
+
+func deviceName() string {
+	if cfg.Device != "" {
+		return cfg.Device
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "device"
+	}
+	return strings.ToLower(strings.SplitN(host, ".", 2)[0])
+}
+
+// syncConflict is a value both devices changed since they last synced.
+// The merged list keeps this device's side until useTheirs is applied.
+type syncConflict struct {
+	todo   string
+	field  string
+	mine   string
+	theirs string
+	// useTheirs applies the other device's side to the merged list.
+	useTheirs func(todos []Todo) []Todo
+}
+
+// syncCommand merges the other devices' replicas into this one.
+func syncCommand(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: todo sync")
+	}
+	if cfg.Sync == "" {
+		return fmt.Errorf("set \"sync\" in %s to a folder your devices share", getConfigFilePath())
+	}
+	merged, err := syncReplicas(isTerminal(os.Stdin) && isTerminal(os.Stdout))
+	if err != nil {
+		return err
+	}
+	switch merged {
+	case 0:
+		fmt.Printf("Nothing was merged into %s.\n", todoFile)
+	case 1:
+		fmt.Printf("Merged 1 other device into %s.\n", todoFile)
+	default:
+		fmt.Printf("Merged %d other devices into %s.\n", merged, todoFile)
+	}
+	return nil
+}
+
+// replicaSuffix ends the name of every replica in the sync folder, so
+// other files kept there are left alone.
+const replicaSuffix = ".todo.json"
+
+// syncReplicas merges every other replica in the sync folder into this
+// device's list and returns how many were merged. Conflicts are shown for
+// resolving when interactive is set; otherwise a replica with conflicts
+// is left out until they can be.
+func syncReplicas(interactive bool) (int, error) {
+	dir := filepath.Dir(todoFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	todos, err := loadTodos()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(todoFile); os.IsNotExist(err) {
+		// The first sync on this device starts from the list it kept
+		// before.
+		if local := localTodoFilePath(); local != todoFile {
+			if todos, _, err = storeFor(local).load(local); err != nil && !os.IsNotExist(err) {
+				return 0, err
+			}
+			sort.SliceStable(todos, func(i, j int) bool {
+				return todos[i].Position < todos[j].Position
+			})
+		}
+	}
+	ensureUIDs(todos)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+replicaSuffix))
+	if err != nil {
+		return 0, err
+	}
+	device := deviceName()
+	bases := map[string][]byte{}
+	for _, path := range paths {
+		peer := strings.TrimSuffix(filepath.Base(path), replicaSuffix)
+		if peer == device {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		theirs, version, err := parseJSONTodos(path, data)
+		if err != nil {
+			// Most likely the sync tool is still writing it.
+			fmt.Printf("Skipping %s: %v\n", peer, err)
+			continue
+		}
+		for v := version; v < schemaVersion; v++ {
+			migrations[v](theirs)
+		}
+
+		basePath := filepath.Join(dir, ".base", device, peer+".json")
+		base := readBase(basePath)
+
+		merged, conflicts := mergeReplica(base, todos, theirs)
+		if len(conflicts) > 0 {
+			if !interactive {
+				fmt.Printf("Skipping %s: %d conflicts. Run \"todo sync\" in a terminal to resolve them:\n", peer, len(conflicts))
+				for _, c := range conflicts {
+					fmt.Printf("  %s: %s is %q here and %q on %s\n", c.todo, c.field, c.mine, c.theirs, peer)
+				}
+				continue
+			}
+			result, err := tea.NewProgram(newConflictModel(peer, conflicts), tea.WithAltScreen()).Run()
+			if err != nil {
+				return 0, err
+			}
+			cm := result.(conflictModel)
+			if !cm.confirmed {
+				return 0, fmt.Errorf("sync cancelled, nothing was changed")
+			}
+			for i, c := range conflicts {
+				if cm.theirs[i] {
+					merged = c.useTheirs(merged)
+				}
+			}
+		}
+		todos = merged
+		bases[basePath] = data
+	}
+
+	for i := range todos {
+		todos[i].Position = i + 1
+	}
+	if err := saveTodos(todos); err != nil {
+		return 0, err
+	}
+	for path, data := range bases {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return 0, err
+		}
+	}
+	return len(bases), nil
+}
+
+// readBase returns the peer's replica as this device last merged it, or
+// nil before the first merge.
+func readBase(path string) []Todo {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	base, _, err := parseJSONTodos(path, data)
+	if err != nil {
+		return nil
+	}
+	return base
+}
+
+// syncField is a single value of a todo that is merged as a whole.
+type syncField struct {
+	name string
+	get  func(Todo) string
+	set  func(dst *Todo, src Todo)
+}
+
+var syncFields = []syncField{
+	{"title", func(t Todo) string { return t.Title }, func(d *Todo, s Todo) { d.Title = s.Title }},
+	{"description", func(t Todo) string { return t.Description }, func(d *Todo, s Todo) { d.Description = s.Description }},
+	{"done", func(t Todo) string { return yesNo(t.Completed) }, func(d *Todo, s Todo) {
+		d.Completed, d.CompletedAt = s.Completed, s.CompletedAt
+	}},
+	{"priority", func(t Todo) string { return t.Priority }, func(d *Todo, s Todo) { d.Priority = s.Priority }},
+	{"due", func(t Todo) string { return formatDate(t.Due) }, func(d *Todo, s Todo) { d.Due = s.Due }},
+	{"deferred until", func(t Todo) string { return formatDate(t.DeferUntil) }, func(d *Todo, s Todo) { d.DeferUntil = s.DeferUntil }},
+	{"recurrence", func(t Todo) string { return t.Recurrence }, func(d *Todo, s Todo) { d.Recurrence = s.Recurrence }},
+	{"archived", func(t Todo) string { return yesNo(t.Archived) }, func(d *Todo, s Todo) { d.Archived = s.Archived }},
+	{"estimate", func(t Todo) string { return syncEstimate(t.Estimate) }, func(d *Todo, s Todo) { d.Estimate = s.Estimate }},
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func syncEstimate(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return formatEstimate(d)
+}
+
+// pick3 decides a three-way merge of one value: theirs wins when only the
+// other device changed it, ours otherwise, and it is a conflict when both
+// changed it to different values.
+func pick3(base, ours, theirs string, hasBase bool) (takeTheirs, conflict bool) {
+	switch {
+	case ours == theirs:
+		return false, false
+	case hasBase && ours == base:
+		return true, false
+	case hasBase && theirs == base:
+		return false, false
+	}
+	return false, true
+}
+
+// mergeReplica merges theirs into ours, base being what both started from.
+// Todos are matched by UID; ours keeps its order and IDs, and todos only
+// theirs has are added at the end.
+func mergeReplica(base, ours, theirs []Todo) ([]Todo, []syncConflict) {
+	// Blockers are stored by ID, and IDs drift apart between devices once
+	// either one deletes a todo, so they are merged by UID instead.
+	var ix uidIndex
+	base, ours, theirs = ix.toIndex(base), ix.toIndex(ours), ix.toIndex(theirs)
+
+	byUID := func(todos []Todo) map[string]Todo {
+		m := map[string]Todo{}
+		for _, todo := range todos {
+			m[todo.UID] = todo
+		}
+		return m
+	}
+	inBase, inOurs, inTheirs := byUID(base), byUID(ours), byUID(theirs)
+
+	var out []Todo
+	var conflicts []syncConflict
+	for _, o := range ours {
+		b, hasB := inBase[o.UID]
+		t, hasT := inTheirs[o.UID]
+		switch {
+		case hasT:
+			tm := todoMerge{uid: o.UID, title: o.Title}
+			out = append(out, tm.merge(b, hasB, o, t))
+			conflicts = append(conflicts, tm.conflicts...)
+		case hasB && sameTodo(b, o):
+			// Deleted on the other device.
+		case hasB:
+			out = append(out, o)
+			uid := o.UID
+			conflicts = append(conflicts, syncConflict{
+				todo: o.Title, field: "todo", mine: "changed", theirs: "deleted",
+				useTheirs: func(todos []Todo) []Todo {
+					return slices.DeleteFunc(todos, func(t Todo) bool { return t.UID == uid })
+				},
+			})
+		default:
+			out = append(out, o)
+		}
+	}
+
+	var added []Todo
+	for _, t := range theirs {
+		if _, ok := inOurs[t.UID]; ok {
+			continue
+		}
+		b, hasB := inBase[t.UID]
+		switch {
+		case hasB && sameTodo(b, t):
+			// Deleted on this device.
+		case hasB:
+			conflicts = append(conflicts, syncConflict{
+				todo: t.Title, field: "todo", mine: "deleted", theirs: "changed",
+				useTheirs: func(todos []Todo) []Todo {
+					t.ID = nextTodoID(todos)
+					t.BlockedBy = ix.resolve(todos, t.BlockedBy)
+					return append(todos, t)
+				},
+			})
+		default:
+			added = append(added, t)
+		}
+	}
+	for _, t := range added {
+		t.ID = nextTodoID(out)
+		out = append(out, t)
+	}
+
+	for i := range out {
+		out[i].Position = i + 1
+	}
+	for i := range out {
+		out[i].BlockedBy = ix.resolve(out, out[i].BlockedBy)
+	}
+	return out, conflicts
+}
+
+func nextTodoID(todos []Todo) int {
+	id := 1
+	for _, todo := range todos {
+		if todo.ID >= id {
+			id = todo.ID + 1
+		}
+	}
+	return id
+}
+
+// uidIndex numbers UIDs across all three lists, so that blockers compare
+// equal on every side.
+type uidIndex struct {
+	uids []string
+}
+
+func (ix *uidIndex) index(uid string) int {
+	if i := slices.Index(ix.uids, uid); i >= 0 {
+		return i + 1
+	}
+	ix.uids = append(ix.uids, uid)
+	return len(ix.uids)
+}
+
+func (ix *uidIndex) toIndex(todos []Todo) []Todo {
+	todos = cloneTodos(todos)
+	uidOf := map[int]string{}
+	for _, todo := range todos {
+		uidOf[todo.ID] = todo.UID
+	}
+	for i := range todos {
+		var blocked []int
+		for _, id := range todos[i].BlockedBy {
+			if uid, ok := uidOf[id]; ok {
+				blocked = append(blocked, ix.index(uid))
+			}
+		}
+		todos[i].BlockedBy = blocked
+	}
+	return todos
+}
+
+// resolve turns indexes back into the IDs the todos have in todos,
+// dropping blockers that aren't there.
+func (ix *uidIndex) resolve(todos []Todo, blocked []int) []int {
+	var ids []int
+	for _, i := range blocked {
+		for _, todo := range todos {
+			if todo.UID == ix.uids[i-1] {
+				ids = append(ids, todo.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// todoMerge merges one todo that both devices still have.
+type todoMerge struct {
+	uid       string
+	title     string
+	conflicts []syncConflict
+}
+
+func (tm *todoMerge) conflict(field, mine, theirs string, set func(*Todo)) {
+	uid := tm.uid
+	tm.conflicts = append(tm.conflicts, syncConflict{
+		todo: tm.title, field: field, mine: mine, theirs: theirs,
+		useTheirs: func(todos []Todo) []Todo {
+			for i := range todos {
+				if todos[i].UID == uid {
+					set(&todos[i])
+				}
+			}
+			return todos
+		},
+	})
+}
+
+func (tm *todoMerge) merge(b Todo, hasB bool, o, t Todo) Todo {
+	merged := o
+	for _, f := range syncFields {
+		takeTheirs, conflict := pick3(f.get(b), f.get(o), f.get(t), hasB)
+		if takeTheirs {
+			f.set(&merged, t)
+		}
+		if conflict {
+			tm.conflict(f.name, f.get(o), f.get(t), func(d *Todo) { f.set(d, t) })
+		}
+	}
+
+	// Lists of labels take what either side added or removed; logs keep
+	// every entry from both.
+	merged.Tags = mergeSet(b.Tags, o.Tags, t.Tags)
+	merged.Projects = mergeSet(b.Projects, o.Projects, t.Projects)
+	merged.Contexts = mergeSet(b.Contexts, o.Contexts, t.Contexts)
+	merged.BlockedBy = mergeSet(b.BlockedBy, o.BlockedBy, t.BlockedBy)
+	merged.TimeEntries = mergeLog(o.TimeEntries, t.TimeEntries, func(e TimeEntry) time.Time { return e.Start })
+	merged.Pomodoros = mergeLog(o.Pomodoros, t.Pomodoros, func(p time.Time) time.Time { return p })
+	merged.Activity = mergeLog(o.Activity, t.Activity, func(a Activity) time.Time { return a.At })
+	merged.Revisions = mergeLog(o.Revisions, t.Revisions, func(r Revision) time.Time { return r.At })
+
+	merged.SubTodos = tm.mergeSubTodos(b.SubTodos, o.SubTodos, t.SubTodos, nil)
+	renumberSubTodos(merged.SubTodos)
+	return merged
+}
+
+// mergeSubTodos matches sub-todos by title, since they have no IDs of
+// their own. A renamed sub-todo is therefore removed and added again.
+func (tm *todoMerge) mergeSubTodos(base, ours, theirs []SubTodo, path []int) []SubTodo {
+	ourKeys, theirKeys := subTodoKeys(ours), subTodoKeys(theirs)
+	inBase, inOurs, inTheirs := keyIndex(subTodoKeys(base)), keyIndex(ourKeys), keyIndex(theirKeys)
+
+	var out []SubTodo
+	for i, o := range ours {
+		b, hasB := lookupSub(base, inBase, ourKeys[i])
+		t, hasT := lookupSub(theirs, inTheirs, ourKeys[i])
+		switch {
+		case hasT:
+			subPath := append(append([]int(nil), path...), len(out))
+			out = append(out, tm.mergeSub(b, hasB, o, t, subPath))
+		case hasB && sameSubTodo(b, o):
+			// Deleted on the other device.
+		default:
+			out = append(out, o)
+		}
+	}
+	for i, t := range theirs {
+		if _, ok := inOurs[theirKeys[i]]; ok {
+			continue
+		}
+		if b, hasB := lookupSub(base, inBase, theirKeys[i]); hasB && sameSubTodo(b, t) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func (tm *todoMerge) mergeSub(b SubTodo, hasB bool, o, t SubTodo, path []int) SubTodo {
+	merged := o
+	name := fmt.Sprintf("sub-todo %q", o.Title)
+	setSub := func(set func(s *SubTodo)) func(*Todo) {
+		return func(d *Todo) {
+			if s := subTodoAt(&d.SubTodos, path); s != nil {
+				set(s)
+			}
+		}
+	}
+
+	takeTheirs, conflict := pick3(yesNo(b.Completed), yesNo(o.Completed), yesNo(t.Completed), hasB)
+	if takeTheirs {
+		merged.Completed = t.Completed
+	}
+	if conflict {
+		tm.conflict(name+" done", yesNo(o.Completed), yesNo(t.Completed), setSub(func(s *SubTodo) { s.Completed = t.Completed }))
+	}
+	takeTheirs, conflict = pick3(syncEstimate(b.Estimate), syncEstimate(o.Estimate), syncEstimate(t.Estimate), hasB)
+	if takeTheirs {
+		merged.Estimate = t.Estimate
+	}
+	if conflict {
+		tm.conflict(name+" estimate", syncEstimate(o.Estimate), syncEstimate(t.Estimate), setSub(func(s *SubTodo) { s.Estimate = t.Estimate }))
+	}
+
+	merged.Children = tm.mergeSubTodos(b.Children, o.Children, t.Children, path)
+	return merged
+}
+
+// subTodoKeys names each sub-todo "title#n", n counting sub-todos with
+// the same title.
+func subTodoKeys(subs []SubTodo) []string {
+	keys := make([]string, len(subs))
+	seen := map[string]int{}
+	for i, sub := range subs {
+		seen[sub.Title]++
+		keys[i] = fmt.Sprintf("%s#%d", sub.Title, seen[sub.Title])
+	}
+	return keys
+}
+
+func keyIndex(keys []string) map[string]int {
+	index := map[string]int{}
+	for i, key := range keys {
+		index[key] = i
+	}
+	return index
+}
+
+func lookupSub(subs []SubTodo, keys map[string]int, key string) (SubTodo, bool) {
+	if i, ok := keys[key]; ok {
+		return subs[i], true
+	}
+	return SubTodo{}, false
+}
+
+func sameSubTodo(a, b SubTodo) bool {
+	ja, _ := json.Marshal(toJSONSubTodos([]SubTodo{a}))
+	jb, _ := json.Marshal(toJSONSubTodos([]SubTodo{b}))
+	return string(ja) == string(jb)
+}
+
+// mergeSet keeps what either side added and drops what either removed.
+func mergeSet[T comparable](base, ours, theirs []T) []T {
+	var out []T
+	for _, v := range ours {
+		if !slices.Contains(base, v) || slices.Contains(theirs, v) {
+			out = append(out, v)
+		}
+	}
+	for _, v := range theirs {
+		if !slices.Contains(base, v) && !slices.Contains(ours, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// mergeLog keeps every entry from both sides once, oldest first.
+func mergeLog[T any](ours, theirs []T, at func(T) time.Time) []T {
+	out := append([]T(nil), ours...)
+	seen := map[string]bool{}
+	for _, v := range ours {
+		data, _ := json.Marshal(v)
+		seen[string(data)] = true
+	}
+	for _, v := range theirs {
+		if data, _ := json.Marshal(v); !seen[string(data)] {
+			out = append(out, v)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return at(out[i]).Before(at(out[j])) })
+	return out
+}
+
+// conflictModel is the screen for picking a side for each conflict.
+type conflictModel struct {
+	peer      string
+	conflicts []syncConflict
+	theirs    []bool
+	cursor    int
+	confirmed bool
+}
+
+func newConflictModel(peer string, conflicts []syncConflict) conflictModel {
+	return conflictModel{peer: peer, conflicts: conflicts, theirs: make([]bool, len(conflicts))}
+}
+
+func (m conflictModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m conflictModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch key.String() {
+	case "ctrl+c", "esc", "q":
+		return m, tea.Quit
+	case "enter":
+		m.confirmed = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.conflicts)-1 {
+			m.cursor++
+		}
+	case "left", "h", "right", "l", " ":
+		m.theirs = append([]bool(nil), m.theirs...)
+		m.theirs[m.cursor] = !m.theirs[m.cursor]
+	case "M", "T":
+		m.theirs = append([]bool(nil), m.theirs...)
+		for i := range m.theirs {
+			m.theirs[i] = key.String() == "T"
+		}
+	}
+	return m, nil
+}
+
+func (m conflictModel) View() string {
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	selected := lipgloss.NewStyle().Foreground(lipgloss.Color("229")).Background(lipgloss.Color("57"))
+	value := func(s string) string {
+		if s == "" {
+			return "(none)"
+		}
+		return truncate(strings.Join(strings.Fields(s), " "), 30)
+	}
+
+	s := titleStyle.Render(fmt.Sprintf("%d conflicts with %s", len(m.conflicts), m.peer)) + "\n\n"
+	s += dim.Render(fmt.Sprintf("  %-54s  %-32s %s", "", "this device", m.peer)) + "\n"
+	for i, c := range m.conflicts {
+		mine, theirs := "● "+value(c.mine), "○ "+value(c.theirs)
+		if m.theirs[i] {
+			mine, theirs = "○ "+value(c.mine), "● "+value(c.theirs)
+		}
+		line := fmt.Sprintf("%-25s %-28s", truncate(c.todo, 25), truncate(c.field, 28))
+		if i == m.cursor {
+			line = selected.Render(line)
+		}
+		s += fmt.Sprintf("  %s  %-32s %s\n", line, mine, theirs)
+	}
+	s += "\n" + dim.Render("[↑/↓] conflict  [←/→] pick a side  [M/T] all this device/"+m.peer+"  [enter] merge  [esc] cancel")
+	return s
+}
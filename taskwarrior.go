@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Taskwarrior's "task export" / "task import" JSON. Priorities H, M and L
+// map to A, B and C, the single project to Projects and annotations to
+// notes. Descriptions have no field of their own, so they are exported as
+// an annotation dated at the task's entry, and an annotation like that is
+// read back as the description.
+
+const twTime = "20060102T150405Z"
+
+type twTask struct {
+	UUID        string         `json:"uuid"`
+	Description string         `json:"description"`
+	Status      string         `json:"status"`
+	Entry       string         `json:"entry"`
+	End         string         `json:"end,omitempty"`
+	Due         string         `json:"due,omitempty"`
+	Wait        string         `json:"wait,omitempty"`
+	Priority    string         `json:"priority,omitempty"`
+	Project     string         `json:"project,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Annotations []twAnnotation `json:"annotations,omitempty"`
+}
+
+type twAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// readTaskwarrior accepts both a JSON array and the one object per line
+// that older versions of "task export" print. Deleted tasks and the
+// templates of recurring ones are skipped.
+func readTaskwarrior(r io.Reader) ([]Todo, error) {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+
+	var tasks []twTask
+	if first, err := firstNonSpace(br); err == nil && first == '[' {
+		if err := dec.Decode(&tasks); err != nil {
+			return nil, err
+		}
+	} else {
+		for dec.More() {
+			var task twTask
+			if err := dec.Decode(&task); err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, task)
+		}
+	}
+
+	var todos []Todo
+	for i, task := range tasks {
+		if task.Status == "deleted" || task.Status == "recurring" {
+			continue
+		}
+		todo, err := task.todo()
+		if err != nil {
+			return nil, fmt.Errorf("task %d (%q): %v", i+1, task.Description, err)
+		}
+		todos = append(todos, todo)
+	}
+	return todos, nil
+}
+
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+func (task twTask) todo() (Todo, error) {
+	todo := Todo{
+		UID:       task.UUID,
+		Title:     task.Description,
+		Completed: task.Status == "completed",
+		Tags:      task.Tags,
+	}
+	if task.Project != "" {
+		todo.Projects = []string{task.Project}
+	}
+	switch task.Priority {
+	case "H":
+		todo.Priority = "A"
+	case "M":
+		todo.Priority = "B"
+	case "L":
+		todo.Priority = "C"
+	}
+
+	var err error
+	if todo.CreatedAt, err = parseTWTime(task.Entry); err != nil {
+		return todo, err
+	}
+	if todo.CompletedAt, err = parseTWTime(task.End); err != nil {
+		return todo, err
+	}
+	if !todo.Completed {
+		todo.CompletedAt = time.Time{}
+	}
+	if todo.Due, err = parseTWTime(task.Due); err != nil {
+		return todo, err
+	}
+	if todo.DeferUntil, err = parseTWTime(task.Wait); err != nil {
+		return todo, err
+	}
+	todo.Due = twDay(todo.Due)
+	todo.DeferUntil = twDay(todo.DeferUntil)
+
+	for i, a := range task.Annotations {
+		at, err := parseTWTime(a.Entry)
+		if err != nil {
+			return todo, err
+		}
+		if i == 0 && a.Entry == task.Entry {
+			todo.Description = a.Description
+			continue
+		}
+		todo.Activity = append(todo.Activity, Activity{At: at, Kind: activityNote, Text: a.Description})
+	}
+	return todo, nil
+}
+
+func parseTWTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(twTime, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q", s)
+	}
+	return t.Local(), nil
+}
+
+func twDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func formatTWTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(twTime)
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// twUUID returns the UID when it is already a UUID, as Taskwarrior
+// requires, and otherwise a UUID derived from it.
+func twUUID(uid string) string {
+	if uuidPattern.MatchString(uid) {
+		return uid
+	}
+	sum := sha1.Sum([]byte(uid))
+	b := sum[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+func writeTaskwarrior(w io.Writer, todos []Todo) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[\n")
+	for i, todo := range todos {
+		task := twTask{
+			UUID:        twUUID(todo.UID),
+			Description: todo.Title,
+			Status:      "pending",
+			Entry:       formatTWTime(todo.CreatedAt),
+			Due:         formatTWTime(todo.Due),
+			Wait:        formatTWTime(todo.DeferUntil),
+			Tags:        todo.Tags,
+		}
+		if task.Entry == "" {
+			task.Entry = formatTWTime(time.Now())
+		}
+		if todo.Completed {
+			task.Status = "completed"
+			task.End = formatTWTime(todo.CompletedAt)
+			if task.End == "" {
+				task.End = task.Entry
+			}
+		}
+		if len(todo.Projects) > 0 {
+			task.Project = todo.Projects[0]
+		}
+		switch {
+		case todo.Priority == "A":
+			task.Priority = "H"
+		case todo.Priority == "B":
+			task.Priority = "M"
+		case todo.Priority != "":
+			task.Priority = "L"
+		}
+
+		if todo.Description != "" {
+			task.Annotations = append(task.Annotations, twAnnotation{task.Entry, todo.Description})
+		}
+		for _, a := range todo.Activity {
+			if a.Kind == activityNote {
+				task.Annotations = append(task.Annotations, twAnnotation{formatTWTime(a.At), a.Text})
+			}
+		}
+
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+		if i < len(todos)-1 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// mergeTaskwarrior copies the fields a Taskwarrior task carries onto an
+// existing todo and adds annotations it doesn't have as notes yet.
+func mergeTaskwarrior(dst *Todo, src Todo) {
+	dst.Title = src.Title
+	if src.Description != "" {
+		dst.Description = src.Description
+	}
+	dst.Completed = src.Completed
+	dst.CompletedAt = src.CompletedAt
+	dst.Due = src.Due
+	dst.DeferUntil = src.DeferUntil
+	dst.Priority = src.Priority
+	dst.Tags = src.Tags
+	dst.Projects = src.Projects
+
+	for _, note := range src.Activity {
+		if !hasNote(dst.Activity, note) {
+			dst.Activity = append(dst.Activity, note)
+		}
+	}
+	sort.SliceStable(dst.Activity, func(i, j int) bool {
+		return dst.Activity[i].At.Before(dst.Activity[j].At)
+	})
+}
+
+func hasNote(activity []Activity, note Activity) bool {
+	for _, a := range activity {
+		if a.Kind == activityNote && a.Text == note.Text && a.At.Truncate(time.Second).Equal(note.At.Truncate(time.Second)) {
+			return true
+		}
+	}
+	return false
+}
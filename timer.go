@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type TimeEntry struct {
+	Start time.Time
+	End   time.Time
+}
+
+type tickMsg time.Time
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+// needsTick reports whether anything on screen counts seconds: a running
+// timer or a focus session.
+func (m *model) needsTick() bool {
+	ri, _ := runningTimer(m.todos)
+	return ri >= 0 || m.focus.todoID != 0
+}
+
+// startTick schedules the next tick when it is needed and none is pending.
+func (m *model) startTick() tea.Cmd {
+	if m.ticking || !m.needsTick() {
+		return nil
+	}
+	m.ticking = true
+	return tick()
+}
+
+// runningTimer finds the open time entry, if any. At most one timer runs
+// at a time across all todos.
+func runningTimer(todos []Todo) (int, int) {
+	for i, todo := range todos {
+		for j, entry := range todo.TimeEntries {
+			if entry.End.IsZero() {
+				return i, j
+			}
+		}
+	}
+	return -1, -1
+}
+
+// toggleTimer stops the timer on a todo if it is running, and otherwise
+// starts one, stopping whatever timer was running before.
+func (m *model) toggleTimer(id int) {
+	i := m.todoIndex(id)
+	if i < 0 {
+		return
+	}
+	now := time.Now()
+
+	ri, rj := runningTimer(m.todos)
+	if ri >= 0 {
+		m.todos[ri].TimeEntries[rj].End = now
+	}
+	if ri != i {
+		m.todos[i].TimeEntries = append(m.todos[i].TimeEntries, TimeEntry{Start: now})
+	}
+
+	m.saveTracking()
+	m.updateTable()
+}
+
+// trackedTime sums the time logged on a todo after since, counting a
+// running entry up to now.
+func trackedTime(todo Todo, since, now time.Time) time.Duration {
+	var total time.Duration
+	for _, entry := range todo.TimeEntries {
+		start, end := entry.Start, entry.End
+		if end.IsZero() {
+			end = now
+		}
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	mins := int(d/time.Minute) % 60
+	secs := int(d/time.Second) % 60
+	if h > 0 {
+		return fmt.Sprintf("%dh%02dm", h, mins)
+	}
+	return fmt.Sprintf("%dm%02ds", mins, secs)
+}
+
+func (m model) timerStatus() string {
+	i, j := runningTimer(m.todos)
+	if i < 0 {
+		return ""
+	}
+	elapsed := time.Since(m.todos[i].TimeEntries[j].Start)
+	return fmt.Sprintf("⏱ #%d %s  %s", m.todos[i].ID, m.todos[i].Title, formatDuration(elapsed))
+}
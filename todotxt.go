@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// readTodoTxt parses the todo.txt format (github.com/todotxt/todo.txt):
+//
+//	x (A) 2024-03-02 2024-03-01 Call mum +family @phone due:2024-03-05
+//
+// Besides +project and @context it understands the due: key, t: for the
+// defer date and pri: for completed tasks that kept their priority. Other
+// key:value pairs stay in the title.
+func readTodoTxt(r io.Reader) ([]Todo, error) {
+	var todos []Todo
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		todo, ok := parseTodoTxtLine(scanner.Text())
+		if !ok {
+			return nil, fmt.Errorf("line %d: task has no text", line)
+		}
+		todos = append(todos, todo)
+	}
+	return todos, scanner.Err()
+}
+
+// parseTodoTxtLine reads one task. It reports false if the task has no
+// text besides its dates, priority and keys.
+func parseTodoTxtLine(text string) (Todo, bool) {
+	var todo Todo
+	words := strings.Fields(text)
+	if len(words) > 0 && words[0] == "x" {
+		todo.Completed = true
+		words = words[1:]
+	}
+	if len(words) > 0 && isTodoTxtPriority(words[0]) {
+		todo.Priority = words[0][1:2]
+		words = words[1:]
+	}
+	if todo.Completed && len(words) > 0 {
+		if d, ok := parseTodoTxtDate(words[0]); ok {
+			todo.CompletedAt = d
+			words = words[1:]
+		}
+	}
+	if len(words) > 0 {
+		if d, ok := parseTodoTxtDate(words[0]); ok {
+			todo.CreatedAt = d
+			words = words[1:]
+		}
+	}
+
+	var title []string
+	for _, word := range words {
+		switch {
+		case len(word) > 1 && word[0] == '+':
+			todo.Projects = append(todo.Projects, word[1:])
+		case len(word) > 1 && word[0] == '@':
+			todo.Contexts = append(todo.Contexts, word[1:])
+		case strings.HasPrefix(word, "due:"):
+			d, ok := parseTodoTxtDate(strings.TrimPrefix(word, "due:"))
+			if !ok {
+				title = append(title, word)
+				continue
+			}
+			todo.Due = d
+		case strings.HasPrefix(word, "t:"):
+			d, ok := parseTodoTxtDate(strings.TrimPrefix(word, "t:"))
+			if !ok {
+				title = append(title, word)
+				continue
+			}
+			todo.DeferUntil = d
+		case strings.HasPrefix(word, "pri:") && isTodoTxtPriority("("+word[4:]+")"):
+			todo.Priority = word[4:]
+		default:
+			title = append(title, word)
+		}
+	}
+	todo.Title = strings.Join(title, " ")
+	return todo, len(title) > 0
+}
+
+func isTodoTxtPriority(word string) bool {
+	return len(word) == 3 && word[0] == '(' && word[1] >= 'A' && word[1] <= 'Z' && word[2] == ')'
+}
+
+func parseTodoTxtDate(word string) (time.Time, bool) {
+	d, err := time.ParseInLocation(dateLayout, word, time.Local)
+	return d, err == nil
+}
+
+// writeTodoTxt writes one line per todo, with its projects, contexts and
+// keys after the title. Descriptions and sub-todos have no place in the
+// format and are left out.
+func writeTodoTxt(w io.Writer, todos []Todo) error {
+	for _, todo := range todos {
+		// A title that starts like the front of a line, "x marks the
+		// spot" or "(A) is for apple", would be read back as such. The
+		// format has no escape for it, but nothing is read after the
+		// creation date, so such a line always gets one.
+		createdAt := todo.CreatedAt
+		if createdAt.IsZero() && misreadTodoTxtTitle(todo.Title) {
+			createdAt = time.Now()
+		}
+
+		var words []string
+		if todo.Completed {
+			words = append(words, "x")
+			// A lone date after "x" is read as the completion date, so
+			// the creation date can only follow one. When the completion
+			// date is unknown, the creation date stands in for it rather
+			// than being dropped.
+			completedAt := todo.CompletedAt
+			if completedAt.IsZero() {
+				completedAt = createdAt
+			}
+			if !completedAt.IsZero() {
+				words = append(words, completedAt.Format(dateLayout))
+				if !createdAt.IsZero() {
+					words = append(words, createdAt.Format(dateLayout))
+				}
+			}
+		} else {
+			if todo.Priority != "" {
+				words = append(words, "("+todo.Priority+")")
+			}
+			if !createdAt.IsZero() {
+				words = append(words, createdAt.Format(dateLayout))
+			}
+		}
+
+		words = append(words, todo.Title)
+		for _, project := range todo.Projects {
+			words = append(words, "+"+project)
+		}
+		for _, context := range todo.Contexts {
+			words = append(words, "@"+context)
+		}
+		if !todo.Due.IsZero() {
+			words = append(words, "due:"+todo.Due.Format(dateLayout))
+		}
+		if !todo.DeferUntil.IsZero() {
+			words = append(words, "t:"+todo.DeferUntil.Format(dateLayout))
+		}
+		if todo.Completed && todo.Priority != "" {
+			words = append(words, "pri:"+todo.Priority)
+		}
+
+		if _, err := fmt.Fprintln(w, strings.Join(words, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// misreadTodoTxtTitle reports whether a title written right after "x" or
+// the priority would be read as a completion mark, priority or date.
+func misreadTodoTxtTitle(title string) bool {
+	first, _, _ := strings.Cut(title, " ")
+	_, isDate := parseTodoTxtDate(first)
+	return first == "x" || isTodoTxtPriority(first) || isDate
+}
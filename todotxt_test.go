@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	want, err := os.ReadFile("testdata/todo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	todos, err := readTodoTxt(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got bytes.Buffer
+	if err := writeTodoTxt(&got, todos); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != string(want) {
+		t.Errorf("round trip changed the file:\ngot:\n%s\nwant:\n%s", got.String(), want)
+	}
+}
+
+// Lines that put projects and contexts mid-sentence come back in the
+// order writeTodoTxt uses, but nothing they say is lost.
+func TestTodoTxtRoundTripKeepsFields(t *testing.T) {
+	in := "(A) 2024-03-01 Call mum +family about  the party @phone due:2024-03-05\n" +
+		"x 2024-03-02 Water +home plants pri:C\n"
+	first, err := readTodoTxt(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeTodoTxt(&buf, first); err != nil {
+		t.Fatal(err)
+	}
+	second, err := readTodoTxt(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("round trip changed the todos:\ngot  %+v\nwant %+v", second, first)
+	}
+}
+
+func TestTodoTxtGuardsTitles(t *testing.T) {
+	todos := []Todo{
+		{Title: "x marks the spot"},
+		{Title: "(A) is for apple"},
+		{Title: "2024-05-01 planning"},
+		{Title: "(B) done already", Completed: true},
+	}
+	var buf bytes.Buffer
+	if err := writeTodoTxt(&buf, todos); err != nil {
+		t.Fatal(err)
+	}
+	back, err := readTodoTxt(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, todo := range back {
+		if todo.Title != todos[i].Title || todo.Completed != todos[i].Completed || todo.Priority != "" {
+			t.Errorf("%q came back as %+v", todos[i].Title, todo)
+		}
+	}
+}
+
+func TestTodoTxtKeepsCreationDateOfCompletedTodo(t *testing.T) {
+	created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	todos := []Todo{{Title: "Water plants", Completed: true, CreatedAt: created}}
+
+	var buf bytes.Buffer
+	if err := writeTodoTxt(&buf, todos); err != nil {
+		t.Fatal(err)
+	}
+	back, err := readTodoTxt(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !back[0].CreatedAt.Equal(created) {
+		t.Errorf("creation date is %v after export, want %v", back[0].CreatedAt, created)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+// subTodoRef locates a sub-todo inside a todo's tree by the index path
+// from the root list down to the item.
+type subTodoRef struct {
+	path  []int
+	depth int
+}
+
+// visibleSubTodos flattens the tree in display order, skipping the
+// children of collapsed items.
+func visibleSubTodos(subTodos []SubTodo) []subTodoRef {
+	var refs []subTodoRef
+	var walk func(list []SubTodo, prefix []int)
+	walk = func(list []SubTodo, prefix []int) {
+		for i, sub := range list {
+			path := append(append([]int{}, prefix...), i)
+			refs = append(refs, subTodoRef{path: path, depth: len(prefix)})
+			if !sub.Collapsed {
+				walk(sub.Children, path)
+			}
+		}
+	}
+	walk(subTodos, nil)
+	return refs
+}
+
+// subTodoList returns the sibling list that holds the item at path.
+func subTodoList(subTodos *[]SubTodo, path []int) *[]SubTodo {
+	list := subTodos
+	for _, idx := range path[:len(path)-1] {
+		if idx < 0 || idx >= len(*list) {
+			return nil
+		}
+		list = &(*list)[idx].Children
+	}
+	return list
+}
+
+func subTodoAt(subTodos *[]SubTodo, path []int) *SubTodo {
+	if len(path) == 0 {
+		return nil
+	}
+	list := subTodoList(subTodos, path)
+	idx := path[len(path)-1]
+	if list == nil || idx < 0 || idx >= len(*list) {
+		return nil
+	}
+	return &(*list)[idx]
+}
+
+func subTodoProgress(subTodos []SubTodo) (int, int) {
+	done, total := 0, 0
+	for _, sub := range subTodos {
+		total++
+		if sub.Completed {
+			done++
+		}
+		childDone, childTotal := subTodoProgress(sub.Children)
+		done += childDone
+		total += childTotal
+	}
+	return done, total
+}
+
+func renumberSubTodos(subTodos []SubTodo) {
+	for i := range subTodos {
+		subTodos[i].ID = i + 1
+		renumberSubTodos(subTodos[i].Children)
+	}
+}
+
+func insertSubTodo(list []SubTodo, pos int, sub SubTodo) []SubTodo {
+	list = append(list, SubTodo{})
+	copy(list[pos+1:], list[pos:])
+	list[pos] = sub
+	return list
+}
+
+func samePath(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func setSubTodosCompleted(subTodos []SubTodo, completed bool) {
+	for i := range subTodos {
+		subTodos[i].Completed = completed
+		setSubTodosCompleted(subTodos[i].Children, completed)
+	}
+}
+
+func hasOpenSubTodos(subTodos []SubTodo) bool {
+	done, total := subTodoProgress(subTodos)
+	return done < total
+}
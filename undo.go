@@ -0,0 +1,80 @@
+package main
+
+import "time"
+
+const maxUndo = 50
+
+// save persists the todos and records the previously saved state as one
+// undo step, so every call is undone as a unit.
+func (m *model) save() {
+	if m.lastSaved != nil {
+		m.undoStack = append(m.undoStack, m.lastSaved)
+		if len(m.undoStack) > maxUndo {
+			m.undoStack = m.undoStack[1:]
+		}
+	}
+	m.lastSaved = cloneTodos(m.todos)
+	saveTodos(m.todos)
+}
+
+// saveTracking saves time logged by the timer or in focus mode. That
+// isn't an edit, so it doesn't become an undo step.
+func (m *model) saveTracking() {
+	m.lastSaved = cloneTodos(m.todos)
+	saveTodos(m.todos)
+}
+
+func (m *model) undo() {
+	if len(m.undoStack) == 0 {
+		return
+	}
+
+	last := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+
+	// Tracked time and pomodoros aren't edits, so undo keeps them.
+	current := map[string]Todo{}
+	for _, todo := range m.todos {
+		current[todo.UID] = todo
+	}
+	m.todos = cloneTodos(last)
+	for i := range m.todos {
+		if todo, ok := current[m.todos[i].UID]; ok {
+			m.todos[i].TimeEntries = append([]TimeEntry(nil), todo.TimeEntries...)
+			m.todos[i].Pomodoros = append([]time.Time(nil), todo.Pomodoros...)
+		}
+	}
+	m.lastSaved = cloneTodos(m.todos)
+	m.marked = map[string]bool{}
+	saveTodos(m.todos)
+	m.updateTable()
+}
+
+func cloneTodos(todos []Todo) []Todo {
+	clone := make([]Todo, len(todos))
+	for i, todo := range todos {
+		clone[i] = todo
+		clone[i].SubTodos = cloneSubTodos(todo.SubTodos)
+		clone[i].Tags = append([]string(nil), todo.Tags...)
+		clone[i].Projects = append([]string(nil), todo.Projects...)
+		clone[i].Contexts = append([]string(nil), todo.Contexts...)
+		clone[i].BlockedBy = append([]int(nil), todo.BlockedBy...)
+		clone[i].TimeEntries = append([]TimeEntry(nil), todo.TimeEntries...)
+		clone[i].Pomodoros = append([]time.Time(nil), todo.Pomodoros...)
+		clone[i].Activity = append([]Activity(nil), todo.Activity...)
+		clone[i].Revisions = append([]Revision(nil), todo.Revisions...)
+	}
+	return clone
+}
+
+func cloneSubTodos(subTodos []SubTodo) []SubTodo {
+	if subTodos == nil {
+		return nil
+	}
+	clone := make([]SubTodo, len(subTodos))
+	for i, sub := range subTodos {
+		clone[i] = sub
+		clone[i].Children = cloneSubTodos(sub.Children)
+	}
+	return clone
+}
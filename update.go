@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
@@ -14,7 +16,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		return m.handleWindowResize(msg), nil
 	case tea.KeyMsg:
-		return m.handleKeyPress(msg)
+		next, cmd := m.handleKeyPress(msg)
+		if nm, ok := next.(model); ok {
+			tickCmd := nm.startTick()
+			return nm, tea.Batch(cmd, tickCmd)
+		}
+		return next, cmd
+	case tickMsg:
+		m.advanceFocus(time.Time(msg))
+		if !m.needsTick() {
+			m.ticking = false
+			return m, nil
+		}
+		return m, tick()
 	}
 
 	if m.mode == tableView {
@@ -34,7 +48,7 @@ func (m model) handleWindowResize(msg tea.WindowSizeMsg) model {
 		availableWidth = 40
 	}
 
-	remainingWidth := availableWidth - 10
+	remainingWidth := availableWidth - 20
 	titleWidth := remainingWidth / 3
 	if titleWidth < 15 {
 		titleWidth = 15
@@ -48,6 +62,7 @@ func (m model) handleWindowResize(msg tea.WindowSizeMsg) model {
 		{Title: "ID", Width: 4},
 		{Title: "Title", Width: titleWidth},
 		{Title: "Description", Width: descWidth},
+		{Title: "Due", Width: 10},
 		{Title: "Done", Width: 6},
 	})
 
@@ -62,6 +77,18 @@ func (m model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleDetailViewKeys(msg)
 	case addView, editView:
 		return m.handleEditViewKeys(msg)
+	case pickParentView:
+		return m.handlePickParentKeys(msg)
+	case confirmView:
+		return m.handleConfirmKeys(msg)
+	case promptView:
+		return m.handlePromptKeys(msg)
+	case snoozeView:
+		return m.handleSnoozeKeys(msg)
+	case focusView:
+		return m.handleFocusKeys(msg)
+	case historyView:
+		return m.handleHistoryKeys(msg)
 	}
 	return m, nil
 }
@@ -69,6 +96,21 @@ func (m model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) handleTableViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	// Keys that act on the todo under the cursor don't apply to a sub-todo
+	// row; space checks the sub-todo off instead.
+	if path := m.currentSubPath(); path != nil {
+		switch msg.String() {
+		case " ":
+			if todo := m.getCurrentTodo(); todo != nil {
+				m.toggleSubTodoAt(todo.ID, path)
+			}
+			return m, nil
+		case "e", "d", "m", "V", "c", "b", "o", "A", "+", "p", "r", "z", "s", "F", "D", ">",
+			"shift+up", "shift+down", "T", "B":
+			return m, nil
+		}
+	}
+
 	switch msg.String() {
 	case "q", "ctrl+c":
 		return m, tea.Quit
@@ -83,16 +125,14 @@ func (m model) handleTableViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if todo != nil {
 			m.mode = editView
 			m.editingID = todo.ID
-			m.titleInput.SetValue(todo.Title)
+			m.titleInput.SetValue(withEstimate(todo.Title, todo.Estimate))
 
 			desc := todo.Description
 			if len(todo.SubTodos) > 0 {
 				if desc != "" {
 					desc += "\n"
 				}
-				for _, sub := range todo.SubTodos {
-					desc += "- " + sub.Title + "\n"
-				}
+				desc += formatSubTodos(todo.SubTodos, 0)
 			}
 			m.descInput.SetValue(strings.TrimSpace(desc))
 
@@ -101,22 +141,186 @@ func (m model) handleTableViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case "d":
+		ids := m.selection()
+		if len(ids) > 0 {
+			m.confirmDestructive(m.describeTodos("Delete", ids), func(m *model) {
+				m.bulkDelete(ids)
+			})
+		}
+		return m, nil
+	case "m":
+		todo := m.getCurrentTodo()
+		if todo != nil {
+			m.toggleMark(todo.ID)
+			m.table.MoveDown(1)
+		}
+		return m, nil
+	case "M":
+		m.markAll()
+		return m, nil
+	case "V":
+		todo := m.getCurrentTodo()
+		if todo != nil {
+			m.markRange(todo.ID)
+		}
+		return m, nil
+	case "esc":
+		m.clearMarks()
+		return m, nil
+	case "u":
+		m.undo()
+		return m, nil
+	case "c":
+		ids := m.selection()
+		blocked := 0
+		for _, id := range ids {
+			if i := m.todoIndex(id); i >= 0 && !m.todos[i].Completed && len(m.openBlockers(m.todos[i])) > 0 {
+				blocked++
+			}
+		}
+		if blocked > 0 {
+			m.askConfirm(
+				fmt.Sprintf("%d of these todos are blocked by open todos. Complete anyway?", blocked),
+				func(m *model) { m.bulkSetCompleted(ids, true) },
+				nil,
+			)
+			return m, nil
+		}
+		m.bulkSetCompleted(ids, true)
+		return m, nil
+	case "b":
 		todo := m.getCurrentTodo()
 		if todo != nil {
-			m.deleteTodo(todo.ID)
+			id := todo.ID
+			m.askInput(fmt.Sprintf("#%d is blocked by", id), "todo numbers, e.g. 2, 5; empty to clear",
+				strings.ReplaceAll(formatTodoList(todo.BlockedBy), "#", ""),
+				func(m *model, value string) error {
+					blockers, err := parseBlockers(value)
+					if err != nil {
+						return err
+					}
+					return m.setBlockers(id, blockers)
+				})
+		}
+		return m, nil
+	case "o":
+		m.bulkSetCompleted(m.selection(), false)
+		return m, nil
+	case "A":
+		ids := m.selection()
+		if len(ids) == 0 {
+			return m, nil
+		}
+		if m.allArchived(ids) {
+			m.bulkSetArchived(ids, false)
+		} else {
+			m.confirmDestructive(m.describeTodos("Archive", ids), func(m *model) {
+				m.bulkSetArchived(ids, true)
+			})
+		}
+		return m, nil
+	case "P":
+		if m.filter == showArchived && len(m.visibleTodoIDs()) > 0 {
+			ids := m.visibleTodoIDs()
+			m.confirmDestructive(m.describeTodos("Permanently delete archived", ids), func(m *model) {
+				m.purgeArchived()
+			})
+		}
+		return m, nil
+	case "+":
+		ids := m.selection()
+		if len(ids) > 0 {
+			m.askInput("Tag", "space-separated tags, prefix with - to remove", "",
+				func(m *model, value string) error {
+					m.bulkTag(ids, value)
+					return nil
+				})
+		}
+		return m, nil
+	case "p":
+		ids := m.selection()
+		if len(ids) > 0 {
+			m.askInput("Priority", "A (highest) to Z, empty to clear", "",
+				func(m *model, value string) error {
+					return m.bulkSetPriority(ids, value)
+				})
+		}
+		return m, nil
+	case "r":
+		ids := m.selection()
+		if len(ids) > 0 {
+			current := ""
+			if i := m.todoIndex(ids[0]); i >= 0 {
+				current = m.todos[i].Recurrence
+			}
+			m.askInput("Repeat", "daily, weekdays, every 2 weeks, monthly on 15, FREQ=WEEKLY;BYDAY=MO; empty to stop", current,
+				func(m *model, value string) error {
+					return m.setRecurrence(ids, value)
+				})
+		}
+		return m, nil
+	case "z":
+		m.startSnooze(m.selection())
+		return m, nil
+	case "s":
+		todo := m.getCurrentTodo()
+		if todo != nil {
+			m.toggleTimer(todo.ID)
+		}
+		return m, nil
+	case "F":
+		todo := m.getCurrentTodo()
+		if todo != nil {
+			m.startFocus(todo.ID)
+		}
+		return m, nil
+	case "D":
+		ids := m.selection()
+		if len(ids) > 0 {
+			m.askInput("Due date", "2006-01-02, today, tomorrow, fri, +3d, +2w; empty to clear", "",
+				func(m *model, value string) error {
+					return m.bulkSetDue(ids, value)
+				})
 		}
 		return m, nil
 	case "enter":
 		m.mode = detailView
 		return m, nil
+	case ">":
+		todo := m.getCurrentTodo()
+		if todo != nil {
+			m.startPickParent(todo.ID)
+		}
+		return m, nil
+	case "tab":
+		todo := m.getCurrentTodo()
+		if todo != nil && len(todo.SubTodos) > 0 {
+			m.toggleExpanded(todo.ID)
+		}
+		return m, nil
 	case " ":
 		todo := m.getCurrentTodo()
 		if todo != nil {
 			m.toggleComplete(todo.ID)
 		}
 		return m, nil
+	case "shift+up", "shift+down", "T", "B":
+		todo := m.getCurrentTodo()
+		if todo != nil {
+			switch msg.String() {
+			case "shift+up":
+				m.moveTodo(todo.ID, -1, false)
+			case "shift+down":
+				m.moveTodo(todo.ID, 1, false)
+			case "T":
+				m.moveTodo(todo.ID, -1, true)
+			case "B":
+				m.moveTodo(todo.ID, 1, true)
+			}
+		}
+		return m, nil
 	case "f":
-		m.filter = (m.filter + 1) % 3
+		m.filter = (m.filter + 1) % filterCount
 		m.updateTable()
 		return m, nil
 	default:
@@ -131,30 +335,113 @@ func (m model) handleDetailViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.subEdit != subEditNone {
+		return m.handleSubInputKeys(msg, todo)
+	}
+
+	visible := len(visibleSubTodos(todo.SubTodos))
+	path := m.selectedSubPath(todo)
+
 	switch msg.String() {
 	case "esc", "q", "enter":
 		m.mode = tableView
 		m.selectedSubIdx = 0
 		return m, nil
-	case "d":
-		m.deleteTodo(todo.ID)
-		m.mode = tableView
+	case "a":
+		m.subEdit = subEditAdd
+		m.subInput.Reset()
+		m.subInput.Focus()
+		return m, nil
+	case "r":
+		if path != nil {
+			m.subEdit = subEditRename
+			sub := subTodoAt(&todo.SubTodos, path)
+			m.subInput.SetValue(withEstimate(sub.Title, sub.Estimate))
+			m.subInput.CursorEnd()
+			m.subInput.Focus()
+		}
+		return m, nil
+	case "x":
+		if path != nil {
+			m.deleteSubTodo(todo.ID, path)
+		}
+		return m, nil
+	case "shift+up":
+		if path != nil {
+			m.moveSubTodo(todo.ID, path, -1)
+		}
+		return m, nil
+	case "shift+down":
+		if path != nil {
+			m.moveSubTodo(todo.ID, path, 1)
+		}
+		return m, nil
+	case "tab":
+		if path != nil {
+			m.indentSubTodo(todo.ID, path)
+		}
+		return m, nil
+	case "shift+tab":
+		if path != nil {
+			m.outdentSubTodo(todo.ID, path)
+		}
+		return m, nil
+	case "left", "right":
+		if path != nil {
+			sub := subTodoAt(&todo.SubTodos, path)
+			if sub.Collapsed == (msg.String() == "right") {
+				m.toggleSubTodoCollapsed(todo.ID, path)
+			}
+		}
+		return m, nil
+	case "s":
+		m.toggleTimer(todo.ID)
+		return m, nil
+	case "F":
+		m.selectedSubIdx = 0
+		m.startFocus(todo.ID)
+		return m, nil
+	case "h":
+		m.startHistory(todo)
+		return m, nil
+	case "n":
+		id := todo.ID
+		m.askInput("Add note", "", "", func(m *model, value string) error {
+			m.addNote(id, value)
+			return nil
+		})
+		return m, nil
+	case "<":
+		if path != nil {
+			m.promoteSubTodo(todo.ID, path)
+		}
+		return m, nil
+	case ">":
 		m.selectedSubIdx = 0
+		m.startPickParent(todo.ID)
+		return m, nil
+	case "d":
+		id := todo.ID
+		m.confirmDestructive(m.describeTodos("Delete", []int{id}), func(m *model) {
+			m.deleteTodo(id)
+			m.mode = tableView
+			m.selectedSubIdx = 0
+		})
 		return m, nil
 	case " ":
-		if len(todo.SubTodos) > 0 && m.selectedSubIdx < len(todo.SubTodos) {
+		if path != nil {
 			m.toggleSubTodo(m.selectedSubIdx)
 		} else {
 			m.toggleComplete(todo.ID)
 		}
 		return m, nil
 	case "up":
-		if len(todo.SubTodos) > 0 && m.selectedSubIdx > 0 {
+		if m.selectedSubIdx > 0 {
 			m.selectedSubIdx--
 		}
 		return m, nil
 	case "down":
-		if len(todo.SubTodos) > 0 && m.selectedSubIdx < len(todo.SubTodos)-1 {
+		if m.selectedSubIdx < visible-1 {
 			m.selectedSubIdx++
 		}
 		return m, nil
@@ -162,6 +449,76 @@ func (m model) handleDetailViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m model) handleSubInputKeys(msg tea.KeyMsg, todo *Todo) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "esc":
+		m.subEdit = subEditNone
+		m.subInput.Blur()
+		return m, nil
+	case "enter":
+		title := strings.TrimSpace(m.subInput.Value())
+		if title != "" {
+			if m.subEdit == subEditAdd {
+				m.addSubTodo(todo.ID, m.selectedSubPath(todo), title)
+			} else {
+				m.renameSubTodo(todo.ID, m.selectedSubPath(todo), title)
+			}
+		}
+		m.subEdit = subEditNone
+		m.subInput.Blur()
+		return m, nil
+	}
+
+	m.subInput, cmd = m.subInput.Update(msg)
+	return m, cmd
+}
+
+func (m *model) startPickParent(id int) {
+	if len(m.pickCandidates(id)) == 0 {
+		return
+	}
+	m.mode = pickParentView
+	m.pickSourceID = id
+	m.pickIdx = 0
+}
+
+func (m *model) pickCandidates(id int) []Todo {
+	var candidates []Todo
+	for _, todo := range m.todos {
+		if todo.ID != id {
+			candidates = append(candidates, todo)
+		}
+	}
+	return candidates
+}
+
+func (m model) handlePickParentKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	candidates := m.pickCandidates(m.pickSourceID)
+
+	switch msg.String() {
+	case "esc", "q":
+		m.mode = tableView
+		return m, nil
+	case "enter":
+		m.mode = tableView
+		if m.pickIdx < len(candidates) {
+			m.demoteTodo(m.pickSourceID, candidates[m.pickIdx].ID)
+		}
+		return m, nil
+	case "up", "k":
+		if m.pickIdx > 0 {
+			m.pickIdx--
+		}
+	case "down", "j":
+		if m.pickIdx < len(candidates)-1 {
+			m.pickIdx++
+		}
+	}
+	return m, nil
+}
+
 func (m model) handleEditViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
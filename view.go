@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -12,6 +14,18 @@ func (m model) View() string {
 		return m.renderDetailView()
 	case addView, editView:
 		return m.renderEditView()
+	case pickParentView:
+		return m.renderPickParentView()
+	case confirmView:
+		return m.renderConfirmView()
+	case promptView:
+		return m.renderPromptView()
+	case snoozeView:
+		return m.renderSnoozeView()
+	case focusView:
+		return m.renderFocusView()
+	case historyView:
+		return m.renderHistoryView()
 	default:
 		return m.renderTableView()
 	}
@@ -43,6 +57,13 @@ func (m model) renderTableView() string {
 		filterStatus = "Active"
 	case showCompleted:
 		filterStatus = "Completed"
+	case showDeferred:
+		filterStatus = "Deferred"
+	case showArchived:
+		filterStatus = "Archived"
+	}
+	if len(m.marked) > 0 {
+		filterStatus += fmt.Sprintf("  ·  %d marked", len(m.marked))
 	}
 
 	filterText := lipgloss.NewStyle().
@@ -51,13 +72,36 @@ func (m model) renderTableView() string {
 		Align(lipgloss.Center).
 		Render(fmt.Sprintf("Filter: %s", filterStatus))
 
+	purgeHelp := ""
+	if m.filter == showArchived {
+		purgeHelp = "  [P] purge archived"
+	}
+
 	help := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241")).
 		Width(m.width).
 		Align(lipgloss.Center).
-		Render("[a] add  [e] edit  [d] delete  [space] toggle  [f] filter  [enter] details  [q] quit")
+		Render("[a] add  [e] edit  [d] delete  [space] toggle  [tab] expand  [shift+↑↓/T/B] reorder  [>] move under  [f] filter  [enter] details  [q] quit\n" +
+			"[m] mark  [M] mark all  [V] mark range  [c/o] complete/reopen  [A] archive  [u] undo" + purgeHelp + "\n" +
+			"[+] tag  [p] priority  [D] due  [r] repeat  [z] snooze  [b] blocked by  [s] timer  [F] focus")
 
-	return baseStyle.Render(m.table.View()) + "\n" + filterText + "\n" + help + "\n"
+	status := ""
+	if estimate := m.estimateSummary(); estimate != "" {
+		status += lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241")).
+			Width(m.width).
+			Align(lipgloss.Center).
+			Render(estimate) + "\n"
+	}
+	if timer := m.timerStatus(); timer != "" {
+		status += lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214")).
+			Width(m.width).
+			Align(lipgloss.Center).
+			Render(timer) + "\n"
+	}
+
+	return baseStyle.Render(m.table.View()) + "\n" + filterText + "\n" + status + help + "\n"
 }
 
 func (m model) renderDetailView() string {
@@ -79,8 +123,52 @@ func (m model) renderDetailView() string {
 		popupWidth = 40
 	}
 
+	if todo.Archived {
+		status += " (archived)"
+	}
+
 	content := fmt.Sprintf("%s\n\n", titleStyle.Render(todo.Title))
-	content += fmt.Sprintf("Status: %s\n\n", status)
+	content += fmt.Sprintf("Status: %s\n", status)
+	if todo.Priority != "" {
+		content += fmt.Sprintf("Priority: %s\n", todo.Priority)
+	}
+	if len(todo.Tags) > 0 {
+		content += fmt.Sprintf("Tags: %s\n", strings.Join(todo.Tags, ", "))
+	}
+	if len(todo.Projects) > 0 {
+		content += fmt.Sprintf("Projects: %s\n", strings.Join(todo.Projects, ", "))
+	}
+	if len(todo.Contexts) > 0 {
+		content += fmt.Sprintf("Contexts: %s\n", strings.Join(todo.Contexts, ", "))
+	}
+	if !todo.Due.IsZero() {
+		content += fmt.Sprintf("Due: %s\n", todo.Due.Format("Mon Jan 2, 2006"))
+	}
+	if todo.Recurrence != "" {
+		content += fmt.Sprintf("Repeats: %s\n", todo.Recurrence)
+	}
+	if isDeferred(*todo, time.Now()) {
+		content += fmt.Sprintf("Deferred until: %s\n", todo.DeferUntil.Format("Mon Jan 2, 2006"))
+	}
+	if len(todo.BlockedBy) > 0 {
+		content += "Blocked by:\n"
+		for _, id := range todo.BlockedBy {
+			if i := m.todoIndex(id); i >= 0 {
+				checkbox := "[ ]"
+				if m.todos[i].Completed {
+					checkbox = "[✓]"
+				}
+				content += fmt.Sprintf("  %s #%d %s\n", checkbox, id, m.todos[i].Title)
+			}
+		}
+	}
+	if dependents := m.dependents(todo.ID); len(dependents) > 0 {
+		content += "Blocking:\n"
+		for _, dep := range dependents {
+			content += fmt.Sprintf("  #%d %s\n", dep.ID, dep.Title)
+		}
+	}
+	content += "\n"
 	content += fmt.Sprintf("Description:\n%s\n\n", todo.Description)
 
 	if !todo.CreatedAt.IsZero() {
@@ -89,16 +177,38 @@ func (m model) renderDetailView() string {
 	if !todo.CompletedAt.IsZero() {
 		content += fmt.Sprintf("Completed: %s\n", todo.CompletedAt.Format("Jan 2, 2006 at 3:04 PM"))
 	}
+	if estimate := todoEstimate(*todo); estimate > 0 {
+		content += fmt.Sprintf("Estimate: %s\n", formatEstimate(estimate))
+	}
+	if len(todo.Pomodoros) > 0 {
+		content += fmt.Sprintf("Pomodoros: %d\n", len(todo.Pomodoros))
+	}
+	if len(todo.TimeEntries) > 0 {
+		tracked := formatDuration(trackedTime(*todo, time.Time{}, time.Now()))
+		if i, _ := runningTimer(m.todos); i >= 0 && m.todos[i].ID == todo.ID {
+			tracked += " (timer running)"
+		}
+		content += fmt.Sprintf("Tracked: %s\n", tracked)
+	}
+
+	if len(todo.SubTodos) > 0 || m.subEdit != subEditNone {
+		done, total := subTodoProgress(todo.SubTodos)
+		content += fmt.Sprintf("\nSub-Todos (%d/%d done):\n", done, total)
+		if m.subEdit == subEditAdd && len(todo.SubTodos) == 0 {
+			content += "  [ ] " + m.subInput.View() + "\n"
+		}
+		for i, ref := range visibleSubTodos(todo.SubTodos) {
+			sub := subTodoAt(&todo.SubTodos, ref.path)
+			checkbox := subTodoCheckbox(*sub)
+			label := subTodoLabel(*sub, ref.depth)
 
-	if len(todo.SubTodos) > 0 {
-		content += "\nSub-Todos:\n"
-		for i, sub := range todo.SubTodos {
-			checkbox := "[ ]"
-			if sub.Completed {
-				checkbox = "[✓]"
+			if m.subEdit == subEditRename && i == m.selectedSubIdx {
+				indent := strings.Repeat("  ", ref.depth)
+				content += fmt.Sprintf("  %s%s %s\n", indent, checkbox, m.subInput.View())
+				continue
 			}
 
-			subLine := fmt.Sprintf("  %s %s", checkbox, sub.Title)
+			subLine := fmt.Sprintf("  %s %s", checkbox, label)
 			if i == m.selectedSubIdx {
 				subLine = lipgloss.NewStyle().
 					Background(lipgloss.Color("57")).
@@ -106,14 +216,38 @@ func (m model) renderDetailView() string {
 					Render(subLine)
 			}
 			content += subLine + "\n"
+
+			if m.subEdit == subEditAdd && i == m.selectedSubIdx {
+				indent := strings.Repeat("  ", ref.depth)
+				content += "  " + indent + "[ ] " + m.subInput.View() + "\n"
+			}
+		}
+	}
+
+	if len(todo.Activity) > 0 {
+		const shown = 8
+		content += "\nActivity:\n"
+		entries := todo.Activity
+		if len(entries) > shown {
+			content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(
+				fmt.Sprintf("  (%d earlier)", len(entries)-shown),
+			) + "\n"
+			entries = entries[len(entries)-shown:]
+		}
+		for _, a := range entries {
+			content += "  " + formatActivity(a) + "\n"
 		}
 	}
 
 	content += "\n"
 
-	helpText := "[enter/esc] back  [d] delete"
-	if len(todo.SubTodos) > 0 {
-		helpText = "[enter/esc] back  [space] toggle sub  [↑↓] navigate  [d] delete"
+	helpText := "[enter/esc] back  [a] add sub  [n] note  [h] history  [s] timer  [F] focus  [>] move under  [d] delete"
+	if m.subEdit != subEditNone {
+		helpText = "[enter] save  [esc] cancel"
+	} else if len(todo.SubTodos) > 0 {
+		helpText = "[enter/esc] back  [space] toggle sub  [↑↓] navigate  [←→] fold  [shift+↑↓] move\n" +
+			"[tab/shift+tab] indent/outdent  [a] add sub  [r] rename sub  [x] remove sub\n" +
+			"[<] promote sub  [>] move under  [n] note  [h] history  [s] timer  [F] focus  [d] delete"
 	}
 	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(helpText)
 
@@ -152,7 +286,7 @@ func (m model) renderEditView() string {
 	content += "Title:\n" + m.titleInput.View() + "\n\n"
 	content += "Description:\n" + m.descInput.View() + "\n"
 	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(
-		"(Use '- ' at start of line for sub-todos)",
+		"(Use '- ' at start of line for sub-todos, '~2h' for an estimate)",
 	) + "\n\n"
 
 	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(
@@ -168,3 +302,44 @@ func (m model) renderEditView() string {
 		popup,
 	)
 }
+
+func (m model) renderPickParentView() string {
+	popupWidth := m.width - 20
+	if popupWidth > 80 {
+		popupWidth = 80
+	}
+	if popupWidth < 40 {
+		popupWidth = 40
+	}
+
+	source := ""
+	if i := m.todoIndex(m.pickSourceID); i >= 0 {
+		source = m.todos[i].Title
+	}
+
+	content := titleStyle.Render(fmt.Sprintf("Move \"%s\" under...", source)) + "\n\n"
+	for i, todo := range m.pickCandidates(m.pickSourceID) {
+		line := fmt.Sprintf("  %d. %s", todo.ID, todo.Title)
+		if i == m.pickIdx {
+			line = lipgloss.NewStyle().
+				Background(lipgloss.Color("57")).
+				Foreground(lipgloss.Color("229")).
+				Render(line)
+		}
+		content += line + "\n"
+	}
+
+	content += "\n"
+	content += lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(
+		"[↑↓] choose parent  [enter] move  [esc] cancel",
+	)
+
+	popup := popupStyle.Width(popupWidth).Render(content)
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		popup,
+	)
+}